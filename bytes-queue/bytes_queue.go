@@ -35,6 +35,7 @@ var (
 	ErrInvalidIndex     = errors.New("Index must be greater than zero, invalid index.")
 	ErrIndexOutOfBounds = errors.New("Index out of range")
 	ErrFullQueue        = errors.New("Full queue. Maximum size limit reached.")
+	ErrInvalidCount     = errors.New("Count must be greater than zero, invalid count.")
 )
 
 // BytesQueue is a non-thread-safe queue type of fifo based on bytes array.
@@ -50,6 +51,7 @@ type BytesQueue struct {
 	count             int
 	headerEntryBuffer []byte
 	verbose           bool
+	store             *mmapStore // nil for in-memory bytes-queue
 }
 
 // getUvarintSize returns the number of bytes to encode x in uvarint format.
@@ -94,12 +96,50 @@ func (q *BytesQueue) Reset() {
 	q.rightMarginIndex = leftMarginIndex
 	q.count = 0
 	q.full = false
+	q.persist()
 }
 
 // Push copies entry at the end of bytes-queue and moves tail pointer.
 // Allocates more space if needed.
 // Returns index of pushed data or error if maximum queue size limit is reached.
+// Indexes stay valid when more space is allocated. Because entries are not moved, growing while the tail
+// has wrapped around before the head makes the entries pushed after the wrap be popped first.
 func (q *BytesQueue) Push(data []byte) (int, error) {
+	if q.closed() {
+		return -1, ErrClosedQueue
+	}
+
+	index, err := q.insert(data)
+	if err != nil {
+		return -1, err
+	}
+	q.persist()
+
+	return index, nil
+}
+
+// PushBatch copies entries at the end of bytes-queue in order.
+// Returns indexes of pushed entries. If an entry can not be pushed, indexes of entries
+// pushed so far are returned along with the error.
+func (q *BytesQueue) PushBatch(entries [][]byte) ([]int, error) {
+	if q.closed() {
+		return nil, ErrClosedQueue
+	}
+
+	indexes := make([]int, 0, len(entries))
+	defer q.persist()
+	for _, data := range entries {
+		index, err := q.insert(data)
+		if err != nil {
+			return indexes, err
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+func (q *BytesQueue) insert(data []byte) (int, error) {
 	dataLen := uint64(len(data))
 	headerEntrySize := getUvarintSize(uint32(dataLen))
 
@@ -108,8 +148,8 @@ func (q *BytesQueue) Push(data []byte) (int, error) {
 			q.tail = leftMarginIndex
 		} else if q.capacity+dataLen+headerEntrySize >= q.maxCapacity && q.maxCapacity > 0 {
 			return -1, ErrFullQueue
-		} else {
-			q.allocateAdditionalMemory(dataLen + headerEntrySize)
+		} else if err := q.allocateAdditionalMemory(dataLen + headerEntrySize); err != nil {
+			return -1, err
 		}
 	}
 
@@ -120,28 +160,38 @@ func (q *BytesQueue) Push(data []byte) (int, error) {
 	return int(index), nil
 }
 
-func (q *BytesQueue) allocateAdditionalMemory(minimum uint64) {
+func (q *BytesQueue) allocateAdditionalMemory(minimum uint64) error {
 	start := time.Now()
 
-	if q.capacity < minimum {
-		q.capacity += minimum
+	capacity := q.capacity
+	if capacity < minimum {
+		capacity += minimum
 	}
-	q.capacity = q.capacity * 2
-	if q.capacity > q.maxCapacity && q.maxCapacity > 0 {
-		q.capacity = q.maxCapacity
+	capacity = capacity * 2
+	if capacity > q.maxCapacity && q.maxCapacity > 0 {
+		capacity = q.maxCapacity
 	}
 
-	oldArray := q.array
-	q.array = make([]byte, q.capacity)
+	if q.store != nil {
+		// the file is remapped in place, so entries stay where they are
+		array, err := q.store.resize(capacity)
+		if err != nil {
+			return err
+		}
+		q.array = array
+	} else {
+		oldArray := q.array
+		q.array = make([]byte, capacity)
+		if leftMarginIndex != q.rightMarginIndex {
+			copy(q.array, oldArray[:q.rightMarginIndex])
+		}
+	}
+	q.capacity = capacity
 
 	if leftMarginIndex != q.rightMarginIndex {
-		copy(q.array, oldArray[:q.rightMarginIndex])
-
 		if q.tail <= q.head {
 			if q.tail != q.head {
-				headerEntrySize := getUvarintSize(uint32(q.head - q.tail))
-				emptyBlobLen := q.head - q.tail - headerEntrySize
-				q.push(make([]byte, emptyBlobLen), emptyBlobLen)
+				q.pushFiller(q.head - q.tail)
 			}
 
 			q.head = leftMarginIndex
@@ -154,6 +204,8 @@ func (q *BytesQueue) allocateAdditionalMemory(minimum uint64) {
 	if q.verbose {
 		log.Printf("Allocated new bytes-queue in %s; Capacity: %d \n", time.Since(start), q.capacity)
 	}
+
+	return nil
 }
 
 /*
@@ -183,35 +235,124 @@ func (q *BytesQueue) copy(data []byte, len uint64) {
 	q.tail += uint64(copy(q.array[q.tail:], data[:len]))
 }
 
+/*
+	The filler entry covers the gap between tail and head when bytes array is reallocated.
+	Its header is padded by at least one byte, e.g. 0x94 0x00 for a filler of 20 bytes blob,
+	which never happens for uvarint written by push, so fillers can be told apart from entries.
+	Fillers are skipped by Pop, Peek and iterator, and are not counted in Len.
+*/
+func (q *BytesQueue) pushFiller(size uint64) {
+	headerEntrySize := getUvarintSize(uint32(size)) + 1
+	blobLen := size - headerEntrySize
+	for i := uint64(0); i < headerEntrySize-1; i++ {
+		q.array[q.tail+i] = byte(blobLen) | 0x80
+		blobLen >>= 7
+	}
+	q.array[q.tail+headerEntrySize-1] = 0x00
+	q.tail += size
+}
+
+// isFiller returns true if the entry at index is a filler entry.
+func (q *BytesQueue) isFiller(index uint64) bool {
+	_, n := binary.Uvarint(q.array[index:])
+	return n > 1 && q.array[index+uint64(n)-1] == 0x00
+}
+
+// following returns the index of the entry next to the one at index.
+func (q *BytesQueue) following(index uint64, headerEntrySize uint64, size uint64) uint64 {
+	next := index + headerEntrySize + size
+	if next == q.rightMarginIndex {
+		next = leftMarginIndex
+	}
+	return next
+}
+
 // Pop reads the oldest entry from bytes-queue and moves head pointer to the next one.
+// Filler entries written on reallocation are skipped.
 func (q *BytesQueue) Pop() ([]byte, error) {
-	data, headerEntrySize, err := q.peek(q.head)
+	data, err := q.pop()
 	if err != nil {
 		return nil, err
 	}
-	size := uint64(len(data))
+	q.persist()
 
-	q.head += headerEntrySize + size
-	q.count--
+	return data, nil
+}
 
-	// deal with empty bytes-queue
-	if q.head == q.rightMarginIndex {
-		q.head = leftMarginIndex
-		if q.tail == q.rightMarginIndex {
-			q.tail = leftMarginIndex
+// PopN reads at most n oldest entries from bytes-queue and moves head pointer past them.
+// Returns ErrInvalidCount if n is not positive, and ErrEmptyQueue only if there is no entry at all.
+func (q *BytesQueue) PopN(n int) ([][]byte, error) {
+	if n <= 0 {
+		return nil, ErrInvalidCount
+	}
+	if q.count == 0 {
+		return nil, q.peekCheckErr(q.head)
+	}
+	if n > q.count {
+		n = q.count
+	}
+
+	entries := make([][]byte, 0, n)
+	defer q.persist()
+	for len(entries) < n {
+		data, err := q.pop()
+		if err != nil {
+			return entries, err
 		}
-		q.rightMarginIndex = q.tail
+		entries = append(entries, data)
 	}
 
-	q.full = false
+	return entries, nil
+}
 
-	return data, nil
+func (q *BytesQueue) pop() ([]byte, error) {
+	for {
+		data, headerEntrySize, err := q.peek(q.head)
+		if err != nil {
+			return nil, err
+		}
+		filler := q.isFiller(q.head)
+		size := uint64(len(data))
+		if q.head+headerEntrySize+size > q.rightMarginIndex {
+			return nil, ErrCorruptedEntry
+		}
+
+		q.head += headerEntrySize + size
+		if !filler {
+			q.count--
+		}
+
+		// deal with empty bytes-queue
+		if q.head == q.rightMarginIndex {
+			q.head = leftMarginIndex
+			if q.tail == q.rightMarginIndex {
+				q.tail = leftMarginIndex
+			}
+			q.rightMarginIndex = q.tail
+		}
+
+		q.full = false
+
+		if !filler {
+			return data, nil
+		}
+	}
 }
 
 // Peek reads the oldest entry from bytes-queue without moving head pointer.
+// Filler entries are skipped as in Pop.
 func (q *BytesQueue) Peek() ([]byte, error) {
-	data, _, err := q.peek(q.head)
-	return data, err
+	index := q.head
+	for {
+		data, headerEntrySize, err := q.peek(index)
+		if err != nil {
+			return nil, err
+		}
+		if !q.isFiller(index) {
+			return data, nil
+		}
+		index = q.following(index, headerEntrySize, uint64(len(data)))
+	}
 }
 
 // Get reads entry at index from bytes-queue.
@@ -225,18 +366,47 @@ func (q *BytesQueue) CheckGet(index int) error {
 	return q.peekCheckErr(uint64(index))
 }
 
+// Sync flushes entries and queue state of a file-backed bytes-queue to disk.
+// It is a no-op for in-memory bytes-queue.
+func (q *BytesQueue) Sync() error {
+	if q.store == nil {
+		return nil
+	}
+	if q.closed() {
+		return ErrClosedQueue
+	}
+	return q.store.sync()
+}
+
+// Close flushes and releases the backing file of a file-backed bytes-queue.
+// It is a no-op for in-memory bytes-queue.
+func (q *BytesQueue) Close() error {
+	if q.store == nil || q.closed() {
+		return nil
+	}
+	err := q.store.sync()
+	if _err := q.store.close(); err == nil {
+		err = _err
+	}
+	q.array = nil
+	return err
+}
+
 // Capacity returns number of allocated bytes for bytes-queue.
 func (q *BytesQueue) Capacity() int {
 	return int(q.capacity)
 }
 
-// Len returns number of entries kept in bytes-queue.
+// Len returns number of entries kept in bytes-queue, filler entries excluded.
 func (q *BytesQueue) Len() int {
 	return q.count
 }
 
 // peekCheckErr is identical to peek, but does not actually return any data.
 func (q *BytesQueue) peekCheckErr(index uint64) error {
+	if q.closed() {
+		return ErrClosedQueue
+	}
 	if q.count == 0 {
 		return ErrEmptyQueue
 	}
@@ -259,7 +429,15 @@ func (q *BytesQueue) peek(index uint64) ([]byte, uint64, error) {
 	// blockSize is the length of the entry
 	// n is the number of bytes to encode the length of the entry in uvarint format
 	blockSize, n := binary.Uvarint(q.array[index:])
+	if n <= 0 {
+		return nil, 0, ErrCorruptedEntry
+	}
 	un := uint64(n)
+	// the length may be garbage if index does not point to an entry, or a data page
+	// of a file-backed bytes-queue was torn by a crash
+	if blockSize > uint64(len(q.array))-index-un {
+		return nil, 0, ErrCorruptedEntry
+	}
 	return q.array[index+un : index+un+blockSize], un, nil
 }
 
@@ -0,0 +1,149 @@
+package bytesqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+)
+
+/*
+	File layout of a mmap-backed bytes-queue:
+
+	| header page (headerPageSize bytes) | bytes array (capacity bytes) |
+
+	The header page keeps the queue state, so that the queue can be recovered
+	after restart:
+
+	| magic (8) | version (4) | flags (4) | capacity (8) | maxCapacity (8) | head (8) | tail (8) | rightMarginIndex (8) | count (8) | crc32 (4) |
+*/
+const (
+	headerPageSize = 4096
+	headerVersion  = 1
+	headerSize     = 8 + 4 + 4 + 8*6 + 4
+
+	flagFull = 1 << 0
+)
+
+var headerMagic = [8]byte{'B', 'Y', 'T', 'E', 'S', 'Q', 'U', 'E'}
+
+var (
+	ErrInvalidQueueFile = errors.New("Invalid bytes-queue file")
+	ErrCorruptedQueue   = errors.New("Corrupted bytes-queue file, header checksum mismatch")
+	ErrClosedQueue      = errors.New("Closed queue")
+	ErrCorruptedEntry   = errors.New("Corrupted bytes-queue entry, length out of range")
+	ErrMmapUnsupported  = errors.New("Mmap-backed bytes-queue is not supported on this platform")
+)
+
+// mmapStore keeps the bytes array of a bytes-queue in a memory-mapped file.
+type mmapStore struct {
+	file    *os.File
+	data    []byte   // the whole mapping, header page included
+	retired [][]byte // mappings replaced on growth, kept until close so that slices returned earlier stay valid
+}
+
+// header returns the header page of the mapping.
+func (s *mmapStore) header() []byte {
+	return s.data[:headerSize]
+}
+
+// closed returns true if the backing file of a file-backed bytes-queue has been closed.
+func (q *BytesQueue) closed() bool {
+	return q.store != nil && q.store.data == nil
+}
+
+// persist writes the queue state into the header page.
+// It is a no-op for in-memory bytes-queue.
+func (q *BytesQueue) persist() {
+	if q.store == nil {
+		return
+	}
+	h := q.store.header()
+	copy(h[0:8], headerMagic[:])
+	binary.LittleEndian.PutUint32(h[8:12], headerVersion)
+	var flags uint32
+	if q.full {
+		flags |= flagFull
+	}
+	binary.LittleEndian.PutUint32(h[12:16], flags)
+	binary.LittleEndian.PutUint64(h[16:24], q.capacity)
+	binary.LittleEndian.PutUint64(h[24:32], q.maxCapacity)
+	binary.LittleEndian.PutUint64(h[32:40], q.head)
+	binary.LittleEndian.PutUint64(h[40:48], q.tail)
+	binary.LittleEndian.PutUint64(h[48:56], q.rightMarginIndex)
+	binary.LittleEndian.PutUint64(h[56:64], uint64(q.count))
+	binary.LittleEndian.PutUint32(h[64:68], crc32.ChecksumIEEE(h[:64]))
+}
+
+// recover loads the queue state from the header page and validates it.
+func (q *BytesQueue) recover() error {
+	h := q.store.header()
+	if string(h[0:8]) != string(headerMagic[:]) {
+		return ErrInvalidQueueFile
+	}
+	if binary.LittleEndian.Uint32(h[64:68]) != crc32.ChecksumIEEE(h[:64]) {
+		return ErrCorruptedQueue
+	}
+	if binary.LittleEndian.Uint32(h[8:12]) != headerVersion {
+		return ErrInvalidQueueFile
+	}
+
+	capacity := binary.LittleEndian.Uint64(h[16:24])
+	head := binary.LittleEndian.Uint64(h[32:40])
+	tail := binary.LittleEndian.Uint64(h[40:48])
+	rightMarginIndex := binary.LittleEndian.Uint64(h[48:56])
+	count := binary.LittleEndian.Uint64(h[56:64])
+
+	if capacity != uint64(len(q.array)) {
+		return ErrInvalidQueueFile
+	}
+	for _, index := range []uint64{head, tail, rightMarginIndex} {
+		if index < leftMarginIndex || index > capacity {
+			return ErrInvalidQueueFile
+		}
+	}
+	if head > rightMarginIndex || tail > rightMarginIndex || count > capacity {
+		return ErrInvalidQueueFile
+	}
+
+	q.full = binary.LittleEndian.Uint32(h[12:16])&flagFull != 0
+	q.capacity = capacity
+	if q.maxCapacity == 0 {
+		q.maxCapacity = binary.LittleEndian.Uint64(h[24:32])
+	}
+	q.head = head
+	q.tail = tail
+	q.rightMarginIndex = rightMarginIndex
+	q.count = int(count)
+	return q.validate()
+}
+
+// validate walks through all entries from head to tail, so that a torn data page is
+// reported when the queue is opened rather than when the entry is popped.
+func (q *BytesQueue) validate() error {
+	index, remaining, walked := q.head, q.count, uint64(0)
+	for remaining > 0 {
+		data, headerEntrySize, err := q.peek(index)
+		if err != nil {
+			return err
+		}
+		if !q.isFiller(index) {
+			remaining--
+		}
+		walked += headerEntrySize + uint64(len(data))
+		index = q.following(index, headerEntrySize, uint64(len(data)))
+		// entries never cover more than the whole array and never run past tail
+		if walked > q.capacity || (remaining > 0 && q.isTail(index)) {
+			return ErrCorruptedEntry
+		}
+	}
+	if q.count > 0 && !q.isTail(index) {
+		return ErrCorruptedEntry
+	}
+	return nil
+}
+
+// isTail returns true if index is where the next entry will be pushed.
+func (q *BytesQueue) isTail(index uint64) bool {
+	return index == q.tail || (index == leftMarginIndex && q.tail == q.rightMarginIndex)
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package bytesqueue
+
+// NewMmapBytesQueue is not supported on this platform, ErrMmapUnsupported is always returned.
+func NewMmapBytesQueue(fn string, capacity int, maxCapacity int, verbose bool) (*BytesQueue, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func (s *mmapStore) sync() error {
+	return ErrMmapUnsupported
+}
+
+func (s *mmapStore) close() error {
+	return ErrMmapUnsupported
+}
+
+func (s *mmapStore) resize(capacity uint64) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
@@ -0,0 +1,112 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package bytesqueue
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// NewMmapBytesQueue opens the bytes-queue persisted in file fn, or creates a new one if
+// the file does not exist or is empty.
+// Entries are kept in a memory-mapped file, so they survive restarts and can exceed RAM.
+// Capacity is only used when a new file is created, otherwise the capacity recorded in file is used.
+// Slices returned by Pop, Peek and Get point into the mapping. They stay valid when the queue grows,
+// because the old mapping is only released on Close, and must not be used after Close.
+func NewMmapBytesQueue(fn string, capacity int, maxCapacity int, verbose bool) (*BytesQueue, error) {
+	file, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() // nolint
+		return nil, err
+	}
+
+	store := &mmapStore{file: file}
+	q := NewBytesQueue(0, maxCapacity, verbose)
+	q.store = store
+
+	if info.Size() == 0 {
+		if capacity < leftMarginIndex {
+			capacity = leftMarginIndex
+		}
+		if q.array, err = store.resize(uint64(capacity)); err != nil {
+			store.close() // nolint
+			return nil, err
+		}
+		q.capacity = uint64(capacity)
+		q.persist()
+		return q, nil
+	}
+
+	if info.Size() < headerPageSize {
+		file.Close() // nolint
+		return nil, ErrInvalidQueueFile
+	}
+	if q.array, err = store.resize(uint64(info.Size() - headerPageSize)); err != nil {
+		store.close() // nolint
+		return nil, err
+	}
+	if err = q.recover(); err != nil {
+		store.close() // nolint
+		return nil, err
+	}
+	return q, nil
+}
+
+// resize truncates the backing file to capacity bytes (header page excluded) and remaps it.
+// The content of the old mapping is kept in place. The old mapping is not unmapped until close,
+// it maps the same file pages, so slices into it keep seeing the entries.
+// If the new mapping fails, the old one stays in use.
+func (s *mmapStore) resize(capacity uint64) ([]byte, error) {
+	size := int64(headerPageSize + capacity)
+	// truncate first, so that the old mapping stays valid if the disk is full
+	if err := s.file.Truncate(size); err != nil {
+		return nil, err
+	}
+	data, err := syscall.Mmap(int(s.file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	if s.data != nil {
+		s.retired = append(s.retired, s.data)
+	}
+	s.data = data
+	return data[headerPageSize:], nil
+}
+
+func (s *mmapStore) sync() error {
+	if err := msync(s.data); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *mmapStore) close() error {
+	var err error
+	for _, data := range append(s.retired, s.data) {
+		if data == nil {
+			continue
+		}
+		if _err := syscall.Munmap(data); err == nil {
+			err = _err
+		}
+	}
+	s.data, s.retired = nil, nil
+	if _err := s.file.Close(); err == nil {
+		err = _err
+	}
+	return err
+}
+
+func msync(b []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
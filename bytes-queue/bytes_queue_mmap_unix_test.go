@@ -0,0 +1,129 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package bytesqueue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMmapBytesQueue(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "queue.dat")
+
+	q, err := NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Empty(t, err)
+	indexes := make(map[int]int)
+	push := func(from, to int) {
+		for i := from; i < to; i++ {
+			index, err := q.Push([]byte(fmt.Sprintf("entry-%02d", i)))
+			assert.Empty(t, err)
+			indexes[i] = index
+		}
+	}
+	// each entry takes 9 bytes, pop enough entries so that tail wraps around before growing
+	push(0, 4)
+	for i := 0; i < 3; i++ {
+		_, err = q.Pop()
+		assert.Empty(t, err)
+	}
+	push(4, 8)
+	assert.Equal(t, 64, q.Capacity())
+	assert.True(t, q.tail < q.head)
+
+	peeked, err := q.Peek()
+	assert.Empty(t, err)
+	// the queue is remapped while tail is wrapped around
+	push(8, 9)
+	assert.True(t, q.Capacity() > 64)
+	// slices returned before growth are still readable
+	assert.Equal(t, "entry-03", string(peeked))
+	// entries stay at their indexes, the one written before head after wrapping comes first,
+	// the gap between it and head is covered by a filler
+	for i := 3; i < 9; i++ {
+		data, err := q.Get(indexes[i])
+		assert.Empty(t, err)
+		assert.Equal(t, fmt.Sprintf("entry-%02d", i), string(data))
+	}
+	order := []string{"entry-07", "entry-03", "entry-04", "entry-05", "entry-06", "entry-08"}
+	var seen []string
+	for it := q.Iterator(); it.Next(); {
+		_, entry := it.Value()
+		seen = append(seen, string(entry))
+	}
+	assert.Equal(t, order, seen)
+
+	push(9, 64)
+	for i := 9; i < 64; i++ {
+		order = append(order, fmt.Sprintf("entry-%02d", i))
+	}
+	capacity := q.Capacity()
+	assert.Empty(t, q.Close())
+
+	_, err = q.Push([]byte("entry"))
+	assert.Equal(t, ErrClosedQueue, err)
+
+	// recover the queue state from file
+	q, err = NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Empty(t, err)
+	assert.Equal(t, capacity, q.Capacity())
+	assert.Equal(t, 61, q.Len())
+	for _, entry := range order {
+		data, err := q.Pop()
+		assert.Empty(t, err)
+		assert.Equal(t, entry, string(data))
+	}
+	_, err = q.Pop()
+	assert.Equal(t, ErrEmptyQueue, err)
+	assert.Empty(t, q.Close())
+}
+
+func TestMmapBytesQueueCorrupted(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "queue.dat")
+
+	q, err := NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Empty(t, err)
+	_, err = q.Push([]byte("entry"))
+	assert.Empty(t, err)
+	assert.Empty(t, q.Close())
+
+	f, err := os.OpenFile(fn, os.O_RDWR, 0644)
+	assert.Empty(t, err)
+	_, err = f.WriteAt([]byte{0xff}, 32) // head
+	assert.Empty(t, err)
+	assert.Empty(t, f.Close())
+
+	_, err = NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Equal(t, ErrCorruptedQueue, err)
+
+	assert.Empty(t, ioutil.WriteFile(fn, []byte("not a bytes-queue"), 0644))
+	_, err = NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Equal(t, ErrInvalidQueueFile, err)
+}
+
+func TestMmapBytesQueueTornEntry(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "queue.dat")
+
+	q, err := NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Empty(t, err)
+	for i := 0; i < 4; i++ {
+		_, err = q.Push([]byte(fmt.Sprintf("entry-%02d", i)))
+		assert.Empty(t, err)
+	}
+	assert.Empty(t, q.Close())
+
+	// the header is intact, but the length of the first entry is garbage
+	f, err := os.OpenFile(fn, os.O_RDWR, 0644)
+	assert.Empty(t, err)
+	_, err = f.WriteAt([]byte{0x7f}, headerPageSize+leftMarginIndex)
+	assert.Empty(t, err)
+	assert.Empty(t, f.Close())
+
+	_, err = NewMmapBytesQueue(fn, 64, 0, false)
+	assert.Equal(t, ErrCorruptedEntry, err)
+}
@@ -0,0 +1,102 @@
+package bytesqueue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesQueue(t *testing.T) {
+	q := NewBytesQueue(64, 0, false)
+
+	indexes := make([]int, 0, 32)
+	for i := 0; i < 32; i++ {
+		index, err := q.Push([]byte(fmt.Sprintf("entry-%02d", i)))
+		assert.Empty(t, err)
+		indexes = append(indexes, index)
+	}
+	assert.Equal(t, 32, q.Len())
+
+	data, err := q.Get(indexes[7])
+	assert.Empty(t, err)
+	assert.Equal(t, "entry-07", string(data))
+
+	for i := 0; i < 32; i++ {
+		data, err := q.Pop()
+		assert.Empty(t, err)
+		assert.Equal(t, fmt.Sprintf("entry-%02d", i), string(data))
+	}
+	_, err = q.Pop()
+	assert.Equal(t, ErrEmptyQueue, err)
+}
+
+func TestBytesQueueIterator(t *testing.T) {
+	q := NewBytesQueue(64, 0, false)
+
+	// make tail wrap around, then force a reallocation so that a filler entry is written
+	_, err := q.PushBatch([][]byte{
+		[]byte("entry-00-0"), []byte("entry-01-0"), []byte("entry-02-0"), []byte("entry-03-0"), []byte("entry-04-0"),
+	})
+	assert.Empty(t, err)
+	popped, err := q.PopN(3)
+	assert.Empty(t, err)
+	assert.Equal(t, [][]byte{[]byte("entry-00-0"), []byte("entry-01-0"), []byte("entry-02-0")}, popped)
+	indexes, err := q.PushBatch([][]byte{
+		[]byte("entry-05-0"), []byte("entry-06-0"), []byte("entry-07-0"),
+	})
+	assert.Empty(t, err)
+	assert.Equal(t, 3, len(indexes))
+	assert.True(t, q.Capacity() > 64)
+	assert.Equal(t, 5, q.Len())
+	assert.True(t, q.isFiller(uint64(indexes[0])+11))
+
+	seen := make([]string, 0, q.Len())
+	it := q.Iterator()
+	for it.Next() {
+		index, entry := it.Value()
+		data, err := q.Get(index)
+		assert.Empty(t, err)
+		assert.Equal(t, entry, data)
+		seen = append(seen, string(entry))
+	}
+	// entry-05 was written before head after wrapping, the gap between it and head is covered
+	// by a filler, entries pushed after the reallocation follow the old right margin
+	assert.Equal(t, []string{"entry-05-0", "entry-03-0", "entry-04-0", "entry-06-0", "entry-07-0"}, seen)
+
+	peeked, err := q.Peek()
+	assert.Empty(t, err)
+	assert.Equal(t, seen[0], string(peeked))
+
+	popped, err = q.PopN(10)
+	assert.Empty(t, err)
+	assert.Equal(t, 5, len(popped))
+	for i := range popped {
+		assert.Equal(t, seen[i], string(popped[i]))
+	}
+	assert.Equal(t, 0, q.Len())
+	assert.False(t, q.Iterator().Next())
+
+	_, err = q.PopN(1)
+	assert.Equal(t, ErrEmptyQueue, err)
+	_, err = q.PopN(0)
+	assert.Equal(t, ErrInvalidCount, err)
+}
+
+func TestBytesQueueCorruptedEntry(t *testing.T) {
+	q := NewBytesQueue(64, 0, false)
+	index, err := q.Push([]byte("entry"))
+	assert.Empty(t, err)
+
+	// entry length points past the end of bytes array
+	q.array[index] = 0x7f
+	_, err = q.Peek()
+	assert.Equal(t, ErrCorruptedEntry, err)
+	_, err = q.Pop()
+	assert.Equal(t, ErrCorruptedEntry, err)
+
+	// entry length points past the right margin
+	q.array[index] = 0x10
+	_, err = q.Pop()
+	assert.Equal(t, ErrCorruptedEntry, err)
+}
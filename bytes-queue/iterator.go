@@ -0,0 +1,47 @@
+package bytesqueue
+
+// BytesQueueIterator allows to iterate over entries of bytes-queue in the order they would be popped.
+// Filler entries are skipped. The iterator is invalidated by any modification of the bytes-queue.
+type BytesQueueIterator struct {
+	q         *BytesQueue
+	next      uint64
+	remaining int
+	index     uint64
+	entry     []byte
+}
+
+// Iterator returns an iterator positioned before the oldest entry of bytes-queue.
+func (q *BytesQueue) Iterator() *BytesQueueIterator {
+	return &BytesQueueIterator{
+		q:         q,
+		next:      q.head,
+		remaining: q.count,
+	}
+}
+
+// Next moves the iterator to the next entry.
+// Returns false if there is no more entries.
+func (it *BytesQueueIterator) Next() bool {
+	for it.remaining > 0 {
+		data, headerEntrySize, err := it.q.peek(it.next)
+		if err != nil {
+			it.remaining = 0
+			return false
+		}
+		index := it.next
+		it.next = it.q.following(index, headerEntrySize, uint64(len(data)))
+		if it.q.isFiller(index) {
+			continue
+		}
+		it.remaining--
+		it.index = index
+		it.entry = data
+		return true
+	}
+	return false
+}
+
+// Value returns the index and the entry the iterator is positioned at.
+func (it *BytesQueueIterator) Value() (int, []byte) {
+	return int(it.index), it.entry
+}
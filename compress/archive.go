@@ -0,0 +1,114 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Format 归档格式
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatZip
+	FormatTar
+	FormatTarGzip
+	FormatTarZstd
+	FormatTarLz4
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatZip:
+		return "zip"
+	case FormatTar:
+		return "tar"
+	case FormatTarGzip:
+		return "tar.gz"
+	case FormatTarZstd:
+		return "tar.zst"
+	case FormatTarLz4:
+		return "tar.lz4"
+	default:
+		return "unknown"
+	}
+}
+
+// codec 返回tar归档所使用的压缩算法.
+func (f Format) codec() Codec {
+	switch f {
+	case FormatTarGzip:
+		return CodecGzip
+	case FormatTarZstd:
+		return CodecZstd
+	case FormatTarLz4:
+		return CodecLz4
+	default:
+		return CodecNone
+	}
+}
+
+var (
+	__MagicZip      = []byte("PK\x03\x04")
+	__MagicZipEmpty = []byte("PK\x05\x06")
+	__MagicTar      = []byte("ustar")
+)
+
+// 识别格式所需读取的文件头部长度, tar的魔数位于偏移257处
+const (
+	__TarMagicOffset = 257
+	__MagicLen       = __TarMagicOffset + 5
+)
+
+// detectFormat 根据文件头部的魔数判断归档格式, 压缩流默认认为其内部是tar归档.
+func detectFormat(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, __MagicZip), bytes.HasPrefix(header, __MagicZipEmpty):
+		return FormatZip
+	case len(header) >= __MagicLen && bytes.Equal(header[__TarMagicOffset:__MagicLen], __MagicTar):
+		return FormatTar
+	}
+	switch detectCodec(header) {
+	case CodecGzip:
+		return FormatTarGzip
+	case CodecZstd:
+		return FormatTarZstd
+	case CodecLz4:
+		return FormatTarLz4
+	default:
+		return FormatUnknown
+	}
+}
+
+// DetectFormat 根据文件头部的魔数判断归档src的格式.
+func DetectFormat(src string) (Format, error) {
+	fr, err := os.Open(src)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer fr.Close()
+
+	header := make([]byte, __MagicLen)
+	n, err := io.ReadFull(fr, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, err
+	}
+	return detectFormat(header[:n]), nil
+}
+
+// Extract 自动识别归档src的格式, 并解压到目录dst, opts为nil时使用默认配置.
+func Extract(dst, src string, opts *Options) error {
+	format, err := DetectFormat(src)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatZip:
+		return UnzipWithOptions(dst, src, opts)
+	case FormatTar, FormatTarGzip, FormatTarZstd, FormatTarLz4:
+		return Untar(dst, src, opts)
+	default:
+		return ErrUnknownFormat
+	}
+}
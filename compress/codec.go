@@ -0,0 +1,152 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+)
+
+// Codec 流式压缩算法
+type Codec int
+
+const (
+	CodecNone Codec = iota
+	CodecGzip
+	CodecZstd
+	CodecLz4
+	CodecSnappy
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecGzip:
+		return "gzip"
+	case CodecZstd:
+		return "zstd"
+	case CodecLz4:
+		return "lz4"
+	case CodecSnappy:
+		return "snappy"
+	default:
+		return "unknown"
+	}
+}
+
+// LevelDefault 使用压缩算法的默认压缩等级
+const LevelDefault = 0
+
+var (
+	__MagicGzip   = []byte{0x1f, 0x8b}
+	__MagicZstd   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	__MagicLz4    = []byte{0x04, 0x22, 0x4d, 0x18}
+	__MagicSnappy = []byte("\xff\x06\x00\x00sNaPpY")
+)
+
+// detectCodec 根据数据头部的魔数判断压缩算法.
+func detectCodec(header []byte) Codec {
+	switch {
+	case bytes.HasPrefix(header, __MagicGzip):
+		return CodecGzip
+	case bytes.HasPrefix(header, __MagicZstd):
+		return CodecZstd
+	case bytes.HasPrefix(header, __MagicLz4):
+		return CodecLz4
+	case bytes.HasPrefix(header, __MagicSnappy):
+		return CodecSnappy
+	default:
+		return CodecNone
+	}
+}
+
+// NewWriter 返回按codec压缩后写入w的写入器, 关闭写入器不会关闭w.
+// level为压缩等级, LevelDefault表示默认等级: gzip取值1~9, zstd取值1~22 (映射到最接近的等级),
+// lz4取值越大压缩率越高, snappy忽略该参数.
+func NewWriter(w io.Writer, codec Codec, level int) (io.WriteCloser, error) {
+	switch codec {
+	case CodecNone:
+		return nopWriteCloser{w}, nil
+	case CodecGzip:
+		if level == LevelDefault {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CodecZstd:
+		if level == LevelDefault {
+			return zstd.NewWriter(w)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	case CodecLz4:
+		lw := lz4.NewWriter(w)
+		lw.Header.CompressionLevel = level
+		return lw, nil
+	case CodecSnappy:
+		return snappy.NewBufferedWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown codec %d", codec)
+	}
+}
+
+// NewReader 根据魔数自动识别r的压缩算法, 返回解压后的读取器; 无法识别时原样读出r中的内容.
+// 支持读取多个压缩流首尾相接的数据, 如NewParallelWriter的输出.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(__MagicSnappy))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch detectCodec(header) {
+	case CodecGzip:
+		return gzip.NewReader(br)
+	case CodecZstd:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &zstdReadCloser{zr}, nil
+	case CodecLz4:
+		return ioutil.NopCloser(lz4.NewReader(br)), nil
+	case CodecSnappy:
+		return ioutil.NopCloser(snappy.NewReader(br)), nil
+	default:
+		return ioutil.NopCloser(br), nil
+	}
+}
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z *zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countingReader 统计从r中读取的字节数.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
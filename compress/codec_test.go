@@ -0,0 +1,108 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var codecs = []Codec{CodecNone, CodecGzip, CodecZstd, CodecLz4, CodecSnappy}
+
+func testPayload(n int) []byte {
+	buf := new(bytes.Buffer)
+	for i := 0; buf.Len() < n; i++ {
+		fmt.Fprintf(buf, "feature-%08d,", i)
+	}
+	return buf.Bytes()[:n]
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	payload := testPayload(1 << 20)
+	for _, codec := range codecs {
+		for _, level := range []int{LevelDefault, 1} {
+			buf := new(bytes.Buffer)
+			w, err := NewWriter(buf, codec, level)
+			assert.Empty(t, err, codec.String())
+			_, err = w.Write(payload)
+			assert.Empty(t, err)
+			assert.Empty(t, w.Close())
+			if codec != CodecNone {
+				assert.True(t, buf.Len() < len(payload), codec.String())
+			}
+
+			r, err := NewReader(buf)
+			assert.Empty(t, err)
+			decoded, err := ioutil.ReadAll(r)
+			assert.Empty(t, err)
+			assert.Empty(t, r.Close())
+			assert.Equal(t, payload, decoded, codec.String())
+		}
+	}
+
+	_, err := NewWriter(new(bytes.Buffer), Codec(100), LevelDefault)
+	assert.NotEmpty(t, err)
+}
+
+func TestParallelWriter(t *testing.T) {
+	payload := testPayload(3<<20 + 12345)
+	for _, codec := range codecs {
+		buf := new(bytes.Buffer)
+		w, err := NewParallelWriter(buf, codec, LevelDefault, 256<<10)
+		assert.Empty(t, err)
+		// 分多次写入, 覆盖跨数据块的情况
+		for p := payload; len(p) > 0; {
+			n := 100000
+			if n > len(p) {
+				n = len(p)
+			}
+			_, err = w.Write(p[:n])
+			assert.Empty(t, err)
+			p = p[n:]
+		}
+		assert.Empty(t, w.Close())
+		assert.Equal(t, int64(buf.Len()), w.Written())
+		_, err = w.Write(payload)
+		assert.Equal(t, ErrClosedWriter, err)
+
+		r, err := NewReader(buf)
+		assert.Empty(t, err)
+		decoded, err := ioutil.ReadAll(r)
+		assert.Empty(t, err)
+		assert.Empty(t, r.Close())
+		assert.Equal(t, payload, decoded, codec.String())
+	}
+}
+
+func BenchmarkWriterGzip(b *testing.B) {
+	benchmarkWriter(b, false)
+}
+
+func BenchmarkParallelWriterGzip(b *testing.B) {
+	benchmarkWriter(b, true)
+}
+
+func benchmarkWriter(b *testing.B, parallel bool) {
+	payload := testPayload(16 << 20)
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var w interface {
+			Write([]byte) (int, error)
+			Close() error
+		}
+		var err error
+		if parallel {
+			w, err = NewParallelWriter(ioutil.Discard, CodecGzip, LevelDefault, 0)
+		} else {
+			w, err = NewWriter(ioutil.Discard, CodecGzip, LevelDefault)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		w.Write(payload) // nolint
+		w.Close()        // nolint
+	}
+}
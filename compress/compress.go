@@ -2,60 +2,215 @@ package compress
 
 import (
 	"archive/zip"
+	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-func Unzip(dst, src string) error {
-	zr, err := zip.OpenReader(src)
+// 符号链接目标路径的长度上限
+const __MaxSymlinkTargetLen = 4096
+
+// Zip 将目录src打包为zip归档dst, opts为nil时使用默认配置.
+// 条目按路径字典序写入并使用统一的修改时间, 相同的目录树总是生成相同的归档; 文件权限会被保留.
+func Zip(dst, src string, opts *Options) (err error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	fw, err := os.Create(dst)
 	if err != nil {
 		return err
 	}
-	defer zr.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(dst) // nolint
+		}
+	}()
 
-	if err = os.MkdirAll(dst, 0644); err != nil {
+	zw := zip.NewWriter(fw)
+	err = walk(src, dst, opts, func(name string, p string, info os.FileInfo) error {
+		return zipFile(zw, name, p, info, opts)
+	})
+	if err != nil {
+		zw.Close() // nolint
+		fw.Close() // nolint
 		return err
 	}
+	if err = zw.Close(); err != nil {
+		fw.Close() // nolint
+		return err
+	}
+	return fw.Close()
+}
 
-	unit := func(path string, file *zip.File) error {
-		fr, err := file.Open()
+func zipFile(zw *zip.Writer, name string, p string, info os.FileInfo, opts *Options) error {
+	fh := &zip.FileHeader{
+		Name:     name,
+		Method:   opts.Method,
+		Modified: opts.modTime(),
+	}
+	fh.SetMode(info.Mode())
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		fh.Method = zip.Store
+		_, err := zw.CreateHeader(fh)
+		return err
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(p)
 		if err != nil {
 			return err
 		}
-		defer fr.Close()
-
-		if err = os.MkdirAll(filepath.Dir(path), file.Mode()); err != nil {
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
 			return err
 		}
-
-		fw, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, file.Mode())
+		_, err = io.WriteString(w, filepath.ToSlash(target))
+		return err
+	case mode.IsRegular():
+		fr, err := os.Open(p)
 		if err != nil {
 			return err
 		}
-		defer fw.Close()
-
-		if _, err = io.Copy(fw, fr); err != nil {
+		defer fr.Close()
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
 			return err
 		}
-
+		_, err = io.Copy(w, fr)
+		return err
+	default:
+		// 设备文件、管道等特殊文件直接忽略
 		return nil
 	}
+}
+
+// Unzip 使用默认配置将zip归档src解压到目录dst.
+func Unzip(dst, src string) error {
+	return UnzipWithOptions(dst, src, nil)
+}
 
+// UnzipWithOptions 将zip归档src解压到目录dst, opts为nil时使用默认配置.
+// 条目路径经过校验, 不会写到dst之外; 超出opts中的限制时返回对应类型的错误.
+func UnzipWithOptions(dst, src string, opts *Options) error {
+	return unzip(context.Background(), dst, src, opts)
+}
+
+// UnzipContext 与UnzipWithOptions相同, 但解压过程可以通过ctx取消, 并通过opts.Progress回调解压进度.
+// 归档先被解压到dst所在目录下的临时目录, 成功后再整体替换dst, 取消或失败时dst保持不变.
+func UnzipContext(ctx context.Context, dst, src string, opts *Options) error {
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	// 临时目录的权限为0700, 替换前改为与dst一致
+	mode := os.FileMode(0755)
+	if fi, err := os.Stat(dst); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = os.Chmod(tmp, mode); err == nil {
+		err = unzip(ctx, tmp, src, opts)
+	}
+	if err != nil {
+		os.RemoveAll(tmp) // nolint
+		return err
+	}
+	if err = replaceDir(dst, tmp); err != nil {
+		os.RemoveAll(tmp) // nolint
+		return err
+	}
+	return nil
+}
+
+func unzip(ctx context.Context, dst, src string, opts *Options) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	ex, err := newExtractor(ctx, dst, opts)
+	if err != nil {
+		return err
+	}
+
+	var total uint64
 	for _, f := range zr.File {
-		p := filepath.Join(dst, f.Name)
+		total += f.UncompressedSize64
+	}
+	if err = ex.precheck(len(zr.File), total); err != nil {
+		return err
+	}
 
-		if f.FileInfo().IsDir() {
-			if err = os.MkdirAll(p, f.Mode()); err != nil {
-				return err
-			}
-			continue
+	for _, f := range zr.File {
+		if err = ex.entry(); err != nil {
+			return err
 		}
-
-		if err = unit(p, f); err != nil {
+		if err = unzipFile(ex, f); err != nil {
 			return err
 		}
+		ex.progress()
 	}
 
 	return nil
 }
+
+// replaceDir 用目录src替换目录dst, dst不存在时直接重命名.
+// dst已存在时先将其移走, 再将src重命名为dst, 最后删除旧目录; 中途失败时会尽量恢复dst.
+func replaceDir(dst, src string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	} else if err != nil {
+		return err
+	}
+
+	old := fmt.Sprintf("%s.old%d", src, time.Now().UnixNano())
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(old, dst) // nolint
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+func unzipFile(ex *extractor, f *zip.File) error {
+	mode := f.Mode()
+	switch {
+	case mode.IsDir():
+		return ex.mkdir(f.Name, mode)
+	case mode&os.ModeSymlink != 0:
+		fr, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer fr.Close()
+		target, err := ioutil.ReadAll(io.LimitReader(fr, __MaxSymlinkTargetLen))
+		if err != nil {
+			return err
+		}
+		return ex.symlink(f.Name, string(target))
+	case mode.IsRegular():
+		fr, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer fr.Close()
+		return ex.writeFile(f.Name, mode, fr, int64(f.CompressedSize64))
+	default:
+		// 设备文件、管道等特殊文件直接忽略
+		return nil
+	}
+}
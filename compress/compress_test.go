@@ -0,0 +1,229 @@
+package compress
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type zipEntry struct {
+	name    string
+	mode    os.FileMode
+	content []byte
+}
+
+func writeZip(t *testing.T, entries []zipEntry) string {
+	fn := filepath.Join(t.TempDir(), "test.zip")
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, entry := range entries {
+		fh := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		fh.SetMode(entry.mode)
+		w, err := zw.CreateHeader(fh)
+		assert.Empty(t, err)
+		_, err = w.Write(entry.content)
+		assert.Empty(t, err)
+	}
+	assert.Empty(t, zw.Close())
+	assert.Empty(t, ioutil.WriteFile(fn, buf.Bytes(), 0644))
+	return fn
+}
+
+func TestUnzip(t *testing.T) {
+	src := writeZip(t, []zipEntry{
+		{name: "model/", mode: os.ModeDir | 0755},
+		{name: "model/meta.json", mode: 0644, content: []byte(`{"version": 1}`)},
+		{name: "model/bin/run.sh", mode: 0755, content: []byte("#!/bin/sh")},
+		{name: "model/latest", mode: os.ModeSymlink | 0777, content: []byte("meta.json")},
+	})
+	dst := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.Symlinks = SymlinkAllow
+	assert.Empty(t, UnzipWithOptions(dst, src, opts))
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "model/meta.json"))
+	assert.Empty(t, err)
+	assert.Equal(t, `{"version": 1}`, string(content))
+	fi, err := os.Stat(filepath.Join(dst, "model/bin/run.sh"))
+	assert.Empty(t, err)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+	target, err := os.Readlink(filepath.Join(dst, "model/latest"))
+	assert.Empty(t, err)
+	assert.Equal(t, "meta.json", target)
+}
+
+func TestUnzipIllegalPath(t *testing.T) {
+	for _, name := range []string{"../evil", "a/../../evil", "/etc/evil"} {
+		src := writeZip(t, []zipEntry{{name: name, mode: 0644, content: []byte("evil")}})
+		err := Unzip(t.TempDir(), src)
+		var e *IllegalPathError
+		assert.True(t, errors.As(err, &e), name)
+	}
+}
+
+func TestUnzipSymlink(t *testing.T) {
+	src := writeZip(t, []zipEntry{
+		{name: "etc", mode: os.ModeSymlink | 0777, content: []byte("../../etc")},
+	})
+	var e *SymlinkError
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), src, &Options{Symlinks: SymlinkAllow}), &e))
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), src, &Options{Symlinks: SymlinkReject}), &e))
+	dst := t.TempDir()
+	assert.Empty(t, UnzipWithOptions(dst, src, &Options{Symlinks: SymlinkSkip}))
+	_, err := os.Lstat(filepath.Join(dst, "etc"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 不允许借由先解压出来的符号链接写文件
+	src = writeZip(t, []zipEntry{
+		{name: "sub", mode: os.ModeDir | 0755},
+		{name: "link", mode: os.ModeSymlink | 0777, content: []byte("sub")},
+		{name: "link/file", mode: 0644, content: []byte("content")},
+	})
+	var pe *IllegalPathError
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), src, &Options{Symlinks: SymlinkAllow}), &pe))
+}
+
+func TestUnzipLimits(t *testing.T) {
+	src := writeZip(t, []zipEntry{
+		{name: "a", mode: 0644, content: bytes.Repeat([]byte("a"), 1024)},
+		{name: "b", mode: 0644, content: bytes.Repeat([]byte("b"), 1024)},
+	})
+	var se *TotalSizeLimitError
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), src, &Options{MaxTotalBytes: 1536}), &se))
+	var ee *EntryLimitError
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), src, &Options{MaxEntries: 1}), &ee))
+	assert.Empty(t, UnzipWithOptions(t.TempDir(), src, &Options{MaxTotalBytes: 2048, MaxEntries: 2}))
+
+	bomb := writeZip(t, []zipEntry{
+		{name: "zeros", mode: 0644, content: make([]byte, 8<<20)},
+	})
+	var re *CompressionRatioError
+	assert.True(t, errors.As(UnzipWithOptions(t.TempDir(), bomb, &Options{MaxCompressionRatio: 10}), &re))
+}
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		assert.Empty(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Empty(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"meta.json":        `{"version": 1}`,
+		"weights/layer.0":  "0000",
+		"weights/layer.1":  "1111",
+		"cache/tmp.bin":    "tmp",
+		"weights/core.tmp": "tmp",
+	})
+	assert.Empty(t, os.Chmod(filepath.Join(src, "weights/layer.1"), 0600))
+	assert.Empty(t, os.Symlink("meta.json", filepath.Join(src, "latest")))
+
+	opts := DefaultOptions()
+	opts.Symlinks = SymlinkAllow
+	opts.Exclude = []string{"cache", "*.tmp"}
+
+	archive := filepath.Join(t.TempDir(), "model.zip")
+	assert.Empty(t, Zip(archive, src, opts))
+	dst := t.TempDir()
+	assert.Empty(t, UnzipWithOptions(dst, archive, opts))
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "weights/layer.0"))
+	assert.Empty(t, err)
+	assert.Equal(t, "0000", string(content))
+	fi, err := os.Stat(filepath.Join(dst, "weights/layer.1"))
+	assert.Empty(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	target, err := os.Readlink(filepath.Join(dst, "latest"))
+	assert.Empty(t, err)
+	assert.Equal(t, "meta.json", target)
+	for _, name := range []string{"cache", "weights/core.tmp"} {
+		_, err = os.Lstat(filepath.Join(dst, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// 相同的目录树总是生成相同的归档
+	another := filepath.Join(t.TempDir(), "model.zip")
+	assert.Empty(t, os.Chtimes(filepath.Join(src, "meta.json"), time.Now(), time.Now().Add(time.Hour)))
+	assert.Empty(t, Zip(another, src, opts))
+	b1, err := ioutil.ReadFile(archive)
+	assert.Empty(t, err)
+	b2, err := ioutil.ReadFile(another)
+	assert.Empty(t, err)
+	assert.Equal(t, b1, b2)
+}
+
+func TestZipIncludeAndMethod(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"a.json":     "a",
+		"sub/b.json": "b",
+		"sub/c.txt":  "c",
+	})
+
+	archive := filepath.Join(t.TempDir(), "json.zip")
+	assert.Empty(t, Zip(archive, src, &Options{Include: []string{"*.json"}, Method: zip.Store}))
+
+	zr, err := zip.OpenReader(archive)
+	assert.Empty(t, err)
+	defer zr.Close()
+	names := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		assert.Equal(t, zip.Store, f.Method)
+	}
+	assert.Equal(t, []string{"a.json", "sub/", "sub/b.json"}, names)
+}
+
+func TestUnzipContext(t *testing.T) {
+	src := writeZip(t, []zipEntry{
+		{name: "a", mode: 0644, content: bytes.Repeat([]byte("a"), 64<<10)},
+		{name: "b", mode: 0644, content: bytes.Repeat([]byte("b"), 64<<10)},
+		{name: "c", mode: 0644, content: bytes.Repeat([]byte("c"), 64<<10)},
+	})
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "model")
+	writeTree(t, dst, map[string]string{"old": "old"})
+
+	// 解压到一半时取消, dst保持不变且不残留临时目录
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := DefaultOptions()
+	opts.Progress = func(p Progress) {
+		if p.Entries == 2 {
+			cancel()
+		}
+	}
+	assert.Equal(t, context.Canceled, UnzipContext(ctx, dst, src, opts))
+	fis, err := ioutil.ReadDir(parent)
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(fis))
+	fis, err = ioutil.ReadDir(dst)
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(fis))
+	assert.Equal(t, "old", fis[0].Name())
+
+	var last Progress
+	opts.Progress = func(p Progress) {
+		assert.True(t, p.Bytes >= last.Bytes && p.Entries >= last.Entries)
+		last = p
+	}
+	assert.Empty(t, UnzipContext(context.Background(), dst, src, opts))
+	assert.Equal(t, Progress{Bytes: 192 << 10, TotalBytes: 192 << 10, Entries: 3, TotalEntries: 3}, last)
+	fis, err = ioutil.ReadDir(dst)
+	assert.Empty(t, err)
+	assert.Equal(t, 3, len(fis))
+	fis, err = ioutil.ReadDir(parent)
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(fis))
+}
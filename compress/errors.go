@@ -0,0 +1,56 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownFormat 无法识别的归档格式
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+// IllegalPathError 条目路径非法, 如绝对路径或者跳出了解压目录 (zip-slip).
+type IllegalPathError struct {
+	Name string
+}
+
+func (e *IllegalPathError) Error() string {
+	return fmt.Sprintf("illegal path %q in archive", e.Name)
+}
+
+// SymlinkError 条目为不被允许的符号链接.
+type SymlinkError struct {
+	Name   string
+	Target string
+}
+
+func (e *SymlinkError) Error() string {
+	return fmt.Sprintf("symlink %q -> %q in archive is not allowed", e.Name, e.Target)
+}
+
+// TotalSizeLimitError 解压后的总字节数超出上限.
+type TotalSizeLimitError struct {
+	Limit int64
+}
+
+func (e *TotalSizeLimitError) Error() string {
+	return fmt.Sprintf("total uncompressed size exceeds limit %d bytes", e.Limit)
+}
+
+// EntryLimitError 归档内的条目数超出上限.
+type EntryLimitError struct {
+	Limit int
+}
+
+func (e *EntryLimitError) Error() string {
+	return fmt.Sprintf("number of entries exceeds limit %d", e.Limit)
+}
+
+// CompressionRatioError 单个文件的压缩比超出上限.
+type CompressionRatioError struct {
+	Name  string
+	Limit float64
+}
+
+func (e *CompressionRatioError) Error() string {
+	return fmt.Sprintf("compression ratio of %q exceeds limit %.1f", e.Name, e.Limit)
+}
@@ -0,0 +1,224 @@
+package compress
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 文件小于该大小时不检查压缩比, 避免误伤高度重复的小文件.
+const __MinRatioCheckBytes = 1 << 20
+
+// extractor 负责将归档条目安全地写入解压目录.
+type extractor struct {
+	ctx     context.Context
+	dst     string
+	opts    *Options
+	entries int
+	written int64
+	total   Progress
+	// raw 统计从压缩流中读取的字节数, 用于检查整个压缩流的压缩比, 仅在无法得知单个条目压缩后大小时设置
+	raw *countingReader
+}
+
+func newExtractor(ctx context.Context, dst string, opts *Options) (*extractor, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dst, 0755); err != nil {
+		return nil, err
+	}
+	return &extractor{
+		ctx:  ctx,
+		dst:  dst,
+		opts: opts,
+	}, nil
+}
+
+// precheck 根据归档头部声明的条目数和解压后大小做快速检查, 头部信息可能被伪造, 解压时仍会按实际字节数检查.
+func (e *extractor) precheck(entries int, total uint64) error {
+	e.total.TotalEntries = entries
+	e.total.TotalBytes = int64(total)
+	if e.opts.MaxEntries > 0 && entries > e.opts.MaxEntries {
+		return &EntryLimitError{Limit: e.opts.MaxEntries}
+	}
+	if e.opts.MaxTotalBytes > 0 && total > uint64(e.opts.MaxTotalBytes) {
+		return &TotalSizeLimitError{Limit: e.opts.MaxTotalBytes}
+	}
+	return nil
+}
+
+// entry 对解压的条目计数, 并检查条目数上限.
+func (e *extractor) entry() error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	e.entries++
+	if e.opts.MaxEntries > 0 && e.entries > e.opts.MaxEntries {
+		return &EntryLimitError{Limit: e.opts.MaxEntries}
+	}
+	return nil
+}
+
+// progress 回调当前的解压进度.
+func (e *extractor) progress() {
+	if e.opts.Progress == nil {
+		return
+	}
+	p := e.total
+	p.Bytes = e.written
+	p.Entries = e.entries
+	e.opts.Progress(p)
+}
+
+// path 返回条目在解压目录下的路径, 保证其不会跳出解压目录.
+func (e *extractor) path(name string) (string, error) {
+	n := filepath.FromSlash(name)
+	if strings.HasPrefix(name, "/") || filepath.IsAbs(n) || filepath.VolumeName(n) != "" {
+		return "", &IllegalPathError{Name: name}
+	}
+	p := filepath.Join(e.dst, n)
+	if !e.within(p) {
+		return "", &IllegalPathError{Name: name}
+	}
+	// 路径上不允许出现符号链接, 防止借由先解压出来的符号链接写到解压目录外部
+	for dir := p; dir != e.dst; dir = filepath.Dir(dir) {
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", &IllegalPathError{Name: name}
+		}
+	}
+	return p, nil
+}
+
+// within 判断路径p是否位于解压目录下.
+func (e *extractor) within(p string) bool {
+	rel, err := filepath.Rel(e.dst, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (e *extractor) mkdir(name string, mode os.FileMode) error {
+	p, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, mode.Perm()|0700)
+}
+
+// writeFile 将r中的内容写入条目对应的文件, compressed为条目压缩后的大小, 用于检查压缩比, 小于0时表示未知.
+func (e *extractor) writeFile(name string, mode os.FileMode, r io.Reader, compressed int64) error {
+	p, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	if p == e.dst {
+		return &IllegalPathError{Name: name}
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	fw, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	gr := &guardReader{
+		r:          r,
+		e:          e,
+		name:       name,
+		ratioLimit: -1,
+	}
+	if e.opts.MaxCompressionRatio > 0 && compressed >= 0 {
+		gr.ratioLimit = int64(e.opts.MaxCompressionRatio * float64(compressed))
+		if gr.ratioLimit < __MinRatioCheckBytes {
+			gr.ratioLimit = __MinRatioCheckBytes
+		}
+	}
+	if _, err = io.Copy(fw, gr); err != nil {
+		fw.Close() // nolint
+		return err
+	}
+	return fw.Close()
+}
+
+// symlink 按照配置的策略处理符号链接条目.
+func (e *extractor) symlink(name, target string) error {
+	switch e.opts.Symlinks {
+	case SymlinkSkip:
+		return nil
+	case SymlinkAllow:
+	default:
+		return &SymlinkError{Name: name, Target: target}
+	}
+
+	p, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	t := filepath.FromSlash(target)
+	if t == "" || filepath.IsAbs(t) || !e.within(filepath.Join(filepath.Dir(p), t)) {
+		return &SymlinkError{Name: name, Target: target}
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(t, p)
+}
+
+// link 创建硬链接条目, 链接目标同样必须位于解压目录下.
+func (e *extractor) link(name, target string) error {
+	p, err := e.path(name)
+	if err != nil {
+		return err
+	}
+	t, err := e.path(target)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.Link(t, p)
+}
+
+// guardReader 在读取过程中按实际字节数检查总大小和压缩比, 并响应取消.
+type guardReader struct {
+	r          io.Reader
+	e          *extractor
+	name       string
+	n          int64
+	ratioLimit int64
+}
+
+func (g *guardReader) Read(p []byte) (int, error) {
+	if err := g.e.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+	g.e.written += int64(n)
+	if g.e.opts.MaxTotalBytes > 0 && g.e.written > g.e.opts.MaxTotalBytes {
+		return n, &TotalSizeLimitError{Limit: g.e.opts.MaxTotalBytes}
+	}
+	if g.ratioLimit >= 0 && g.n > g.ratioLimit {
+		return n, &CompressionRatioError{Name: g.name, Limit: g.e.opts.MaxCompressionRatio}
+	}
+	if ratio := g.e.opts.MaxCompressionRatio; ratio > 0 && g.e.raw != nil &&
+		g.e.written > __MinRatioCheckBytes && float64(g.e.written) > ratio*float64(g.e.raw.n) {
+		return n, &CompressionRatioError{Name: g.name, Limit: ratio}
+	}
+	g.e.progress()
+	return n, err
+}
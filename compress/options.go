@@ -0,0 +1,92 @@
+package compress
+
+import (
+	"archive/zip"
+	"path"
+	"time"
+)
+
+// SymlinkPolicy 归档中符号链接的处理策略
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip 忽略符号链接
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkReject 遇到符号链接直接报错
+	SymlinkReject
+	// SymlinkAllow 允许指向解压目录内部的符号链接, 指向目录外部的报错
+	SymlinkAllow
+)
+
+const (
+	__DefaultMaxTotalBytes       = 16 << 30 // 16GB
+	__DefaultMaxEntries          = 1 << 16
+	__DefaultMaxCompressionRatio = 1000
+)
+
+// Progress 解压进度, 总量取自归档头部的声明.
+type Progress struct {
+	Bytes        int64 // 已解压的字节数
+	TotalBytes   int64
+	Entries      int // 已解压的条目数
+	TotalEntries int
+}
+
+// 归档内条目默认使用的修改时间, 即zip格式所能表示的最早时间.
+var __DefaultModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Options 解压/压缩配置, 各项限制取值为0时表示不做限制.
+type Options struct {
+	MaxTotalBytes       int64   // 解压后的总字节数上限
+	MaxEntries          int     // 归档内的条目数上限
+	MaxCompressionRatio float64 // 单个文件的压缩比上限 (解压后大小/压缩后大小)
+	Symlinks            SymlinkPolicy
+	Progress            func(Progress) // 解压进度回调, 在解压协程中同步调用, 不应阻塞
+
+	// 以下配置仅在创建归档时使用
+	Include []string  // 需要打包的路径的glob模式, 为空时打包全部
+	Exclude []string  // 不需要打包的路径的glob模式, 被排除的目录不会再被遍历
+	Method  uint16    // zip压缩方法, zip.Store或zip.Deflate, DefaultOptions中为zip.Deflate
+	ModTime time.Time // 归档内条目统一使用的修改时间, 零值时使用1980-01-01, 保证归档内容可复现
+}
+
+// DefaultOptions 返回默认的解压配置.
+func DefaultOptions() *Options {
+	return &Options{
+		MaxTotalBytes:       __DefaultMaxTotalBytes,
+		MaxEntries:          __DefaultMaxEntries,
+		MaxCompressionRatio: __DefaultMaxCompressionRatio,
+		Symlinks:            SymlinkSkip,
+		Method:              zip.Deflate,
+	}
+}
+
+// included 判断归档内路径name是否需要打包, 模式同时匹配完整路径和文件名.
+func (opts *Options) included(name string, isDir bool) bool {
+	if matchAny(opts.Exclude, name) {
+		return false
+	}
+	if len(opts.Include) == 0 || isDir {
+		return true
+	}
+	return matchAny(opts.Include, name)
+}
+
+func (opts *Options) modTime() time.Time {
+	if opts.ModTime.IsZero() {
+		return __DefaultModTime
+	}
+	return opts.ModTime
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
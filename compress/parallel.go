@@ -0,0 +1,205 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 并行压缩时每个数据块的默认大小
+const __DefaultBlockSize = 1 << 20
+
+// ErrClosedWriter 写入器已关闭
+var ErrClosedWriter = errors.New("compress: writer is closed")
+
+// block 并行压缩的数据块, 每个数据块被压缩为一个独立的压缩流.
+type block struct {
+	data []byte
+	out  []byte
+	err  error
+	done chan struct{}
+}
+
+// ParallelWriter 并行压缩写入器 (非线程安全).
+// 输入被切分为固定大小的数据块, 各数据块由多个协程并行压缩为独立的压缩流, 再按顺序写入下游.
+// gzip、zstd、lz4和snappy均支持多个压缩流首尾相接, 因此输出可以直接由NewReader读取.
+type ParallelWriter struct {
+	w         io.Writer
+	codec     Codec
+	level     int
+	blockSize int
+	zenc      *zstd.Encoder
+
+	buf     []byte
+	blocks  int
+	sem     chan struct{}
+	queue   chan *block
+	exited  chan struct{}
+	pool    sync.Pool
+	mu      sync.Mutex
+	err     error
+	closed  bool
+	written int64
+}
+
+// NewParallelWriter 返回按codec并行压缩后写入w的写入器, 关闭写入器不会关闭w.
+// level的含义与NewWriter相同, blockSize为数据块大小, 取值不大于0时使用默认值1MB; 并发数为CPU核数.
+func NewParallelWriter(w io.Writer, codec Codec, level int, blockSize int) (*ParallelWriter, error) {
+	if blockSize <= 0 {
+		blockSize = __DefaultBlockSize
+	}
+	// 提前校验codec和level
+	if _, err := compressBlock(nil, codec, level, nil); err != nil {
+		return nil, err
+	}
+
+	workers := runtime.NumCPU()
+	pw := &ParallelWriter{
+		w:         w,
+		codec:     codec,
+		level:     level,
+		blockSize: blockSize,
+		sem:       make(chan struct{}, workers),
+		queue:     make(chan *block, workers),
+		exited:    make(chan struct{}),
+	}
+	pw.pool.New = func() interface{} {
+		return make([]byte, 0, blockSize)
+	}
+	if codec == CodecZstd {
+		// zstd.Encoder.EncodeAll可以被并发调用, 共享同一个编码器即可
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(workers)}
+		if level != LevelDefault {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		enc, err := zstd.NewWriter(nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		pw.zenc = enc
+	}
+	pw.buf = pw.pool.Get().([]byte)
+
+	go pw.output()
+
+	return pw, nil
+}
+
+// Write 写字节流, 数据块写满后交由后台协程压缩.
+func (pw *ParallelWriter) Write(p []byte) (int, error) {
+	if pw.closed {
+		return 0, ErrClosedWriter
+	}
+	n := 0
+	for len(p) > 0 {
+		if err := pw.loadErr(); err != nil {
+			return n, err
+		}
+		c := copy(pw.buf[len(pw.buf):pw.blockSize], p)
+		pw.buf = pw.buf[:len(pw.buf)+c]
+		p = p[c:]
+		n += c
+		if len(pw.buf) == pw.blockSize {
+			pw.flush()
+		}
+	}
+	return n, nil
+}
+
+// Close 压缩剩余的数据, 等待所有数据块写入下游.
+func (pw *ParallelWriter) Close() error {
+	if pw.closed {
+		return pw.loadErr()
+	}
+	pw.closed = true
+	// 没有任何输入时也要输出一个空的压缩流, 以便读取端能识别
+	if len(pw.buf) > 0 || pw.blocks == 0 {
+		pw.flush()
+	}
+	close(pw.queue)
+	<-pw.exited
+	if pw.zenc != nil {
+		pw.zenc.Close() // nolint
+	}
+	return pw.loadErr()
+}
+
+// Written 返回已写入下游的压缩后字节数.
+func (pw *ParallelWriter) Written() int64 {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.written
+}
+
+func (pw *ParallelWriter) flush() {
+	b := &block{
+		data: pw.buf,
+		done: make(chan struct{}),
+	}
+	pw.buf = pw.pool.Get().([]byte)[:0]
+	pw.blocks++
+
+	pw.sem <- struct{}{}
+	go func() {
+		if pw.zenc != nil {
+			b.out = pw.zenc.EncodeAll(b.data, make([]byte, 0, len(b.data)/2))
+		} else {
+			b.out, b.err = compressBlock(make([]byte, 0, len(b.data)/2), pw.codec, pw.level, b.data)
+		}
+		<-pw.sem
+		close(b.done)
+	}()
+	// 队列满时阻塞, 避免待压缩的数据无限堆积
+	pw.queue <- b
+}
+
+// output 按顺序将压缩后的数据块写入下游.
+func (pw *ParallelWriter) output() {
+	defer close(pw.exited)
+	for b := range pw.queue {
+		<-b.done
+		err := b.err
+		if err == nil && pw.loadErr() == nil {
+			var n int
+			n, err = pw.w.Write(b.out)
+			pw.mu.Lock()
+			pw.written += int64(n)
+			pw.mu.Unlock()
+		}
+		if err != nil {
+			pw.mu.Lock()
+			if pw.err == nil {
+				pw.err = err
+			}
+			pw.mu.Unlock()
+		}
+		pw.pool.Put(b.data[:0])
+	}
+}
+
+func (pw *ParallelWriter) loadErr() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+// compressBlock 将data压缩为一个独立的压缩流, 追加到dst之后返回.
+func compressBlock(dst []byte, codec Codec, level int, data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	cw, err := NewWriter(buf, codec, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = cw.Write(data); err != nil {
+		cw.Close() // nolint
+		return nil, err
+	}
+	if err = cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package compress
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Untar 将tar归档src解压到目录dst, opts为nil时使用默认配置.
+// 支持gzip、zstd和lz4压缩的tar归档, 压缩方式根据魔数自动识别; 安全限制与Unzip相同.
+func Untar(dst, src string, opts *Options) error {
+	fr, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	br := bufio.NewReader(fr)
+	header, err := br.Peek(__MagicLen)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	format := detectFormat(header)
+	if format == FormatZip || format == FormatUnknown {
+		return ErrUnknownFormat
+	}
+	raw := &countingReader{r: br}
+	r, err := NewReader(raw)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ex, err := newExtractor(context.Background(), dst, opts)
+	if err != nil {
+		return err
+	}
+	if format != FormatTar {
+		ex.raw = raw
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = ex.entry(); err != nil {
+			return err
+		}
+		if err = untarFile(ex, tr, hdr); err != nil {
+			return err
+		}
+		ex.progress()
+	}
+}
+
+func untarFile(ex *extractor, tr *tar.Reader, hdr *tar.Header) error {
+	mode := hdr.FileInfo().Mode()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return ex.mkdir(hdr.Name, mode)
+	case tar.TypeReg:
+		return ex.writeFile(hdr.Name, mode, tr, -1)
+	case tar.TypeSymlink:
+		return ex.symlink(hdr.Name, hdr.Linkname)
+	case tar.TypeLink:
+		return ex.link(hdr.Name, hdr.Linkname)
+	default:
+		// 设备文件、管道等特殊文件直接忽略
+		return nil
+	}
+}
+
+// Tar 将目录src打包为format格式的tar归档dst, format可以是FormatTar、FormatTarGzip、FormatTarZstd或FormatTarLz4.
+// opts为nil时使用默认配置, 与Zip一样, 相同的目录树总是生成相同的归档.
+func Tar(dst, src string, format Format, opts *Options) (err error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+
+	fw, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(dst) // nolint
+		}
+	}()
+
+	if format != FormatTar && format.codec() == CodecNone {
+		fw.Close() // nolint
+		return ErrUnknownFormat
+	}
+	cw, err := NewWriter(fw, format.codec(), LevelDefault)
+	if err != nil {
+		fw.Close() // nolint
+		return err
+	}
+	tw := tar.NewWriter(cw)
+	err = walk(src, dst, opts, func(name string, p string, info os.FileInfo) error {
+		return tarFile(tw, name, p, info, opts)
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if _err := cw.Close(); err == nil {
+		err = _err
+	}
+	if _err := fw.Close(); err == nil {
+		err = _err
+	}
+	return err
+}
+
+func tarFile(tw *tar.Writer, name string, p string, info os.FileInfo, opts *Options) error {
+	var link string
+	switch mode := info.Mode(); {
+	case mode.IsDir(), mode.IsRegular():
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		link = filepath.ToSlash(target)
+	default:
+		// 设备文件、管道等特殊文件直接忽略
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.ModTime = opts.modTime()
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	// 属主随机器和用户而变, 与修改时间一样不写入归档
+	hdr.Uid, hdr.Gid = 0, 0
+	hdr.Uname, hdr.Gname = "", ""
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	fr, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	_, err = io.Copy(tw, fr)
+	return err
+}
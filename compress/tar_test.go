@@ -0,0 +1,129 @@
+package compress
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"meta.json":       `{"version": 1}`,
+		"weights/layer.0": "0000",
+		"weights/layer.1": "1111",
+	})
+	assert.Empty(t, os.Symlink("meta.json", filepath.Join(src, "latest")))
+
+	opts := DefaultOptions()
+	opts.Symlinks = SymlinkAllow
+
+	for _, format := range []Format{FormatTar, FormatTarGzip, FormatTarZstd, FormatTarLz4} {
+		archive := filepath.Join(t.TempDir(), "model."+format.String())
+		assert.Empty(t, Tar(archive, src, format, opts), format.String())
+
+		detected, err := DetectFormat(archive)
+		assert.Empty(t, err)
+		assert.Equal(t, format, detected)
+
+		dst := t.TempDir()
+		assert.Empty(t, Extract(dst, archive, opts), format.String())
+		content, err := ioutil.ReadFile(filepath.Join(dst, "weights/layer.1"))
+		assert.Empty(t, err)
+		assert.Equal(t, "1111", string(content))
+		target, err := os.Readlink(filepath.Join(dst, "latest"))
+		assert.Empty(t, err)
+		assert.Equal(t, "meta.json", target)
+	}
+
+	archive := filepath.Join(t.TempDir(), "model.zip")
+	assert.Empty(t, Zip(archive, src, opts))
+	detected, err := DetectFormat(archive)
+	assert.Empty(t, err)
+	assert.Equal(t, FormatZip, detected)
+	assert.Empty(t, Extract(t.TempDir(), archive, opts))
+
+	assert.Equal(t, ErrUnknownFormat, Extract(t.TempDir(), filepath.Join(src, "meta.json"), opts))
+}
+
+func TestTarDeterministic(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"meta.json":       `{"version": 1}`,
+		"weights/layer.0": "0000",
+	})
+	archive := filepath.Join(t.TempDir(), "model.tar")
+	assert.Empty(t, Tar(archive, src, FormatTar, nil))
+
+	f, err := os.Open(archive)
+	assert.Empty(t, err)
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		assert.Equal(t, 0, hdr.Uid, hdr.Name)
+		assert.Equal(t, 0, hdr.Gid, hdr.Name)
+		assert.Equal(t, "", hdr.Uname, hdr.Name)
+		assert.Equal(t, "", hdr.Gname, hdr.Name)
+	}
+
+	// 修改时间和属主 (仅root可修改) 不同的相同目录树生成相同的归档
+	another := filepath.Join(t.TempDir(), "model.tar")
+	assert.Empty(t, os.Chtimes(filepath.Join(src, "meta.json"), time.Now(), time.Now().Add(time.Hour)))
+	os.Lchown(filepath.Join(src, "weights/layer.0"), 1234, 1234) // nolint
+	assert.Empty(t, Tar(another, src, FormatTar, nil))
+	b1, err := ioutil.ReadFile(archive)
+	assert.Empty(t, err)
+	b2, err := ioutil.ReadFile(another)
+	assert.Empty(t, err)
+	assert.Equal(t, b1, b2)
+}
+
+func TestUntarIllegalPath(t *testing.T) {
+	for _, hdr := range []*tar.Header{
+		{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "/etc/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		{Name: "evil", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"},
+	} {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		assert.Empty(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte("evil"))
+			assert.Empty(t, err)
+		}
+		assert.Empty(t, tw.Close())
+		src := filepath.Join(t.TempDir(), "evil.tar")
+		assert.Empty(t, ioutil.WriteFile(src, buf.Bytes(), 0644))
+
+		var e *IllegalPathError
+		assert.True(t, errors.As(Untar(t.TempDir(), src, nil), &e), hdr.Name)
+	}
+}
+
+func TestUntarLimits(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"zeros":     string(make([]byte, 8<<20)),
+		"meta.json": `{"version": 1}`,
+	})
+	archive := filepath.Join(t.TempDir(), "bomb.tar.zst")
+	assert.Empty(t, Tar(archive, src, FormatTarZstd, nil))
+
+	var re *CompressionRatioError
+	assert.True(t, errors.As(Untar(t.TempDir(), archive, &Options{MaxCompressionRatio: 10}), &re))
+	var se *TotalSizeLimitError
+	assert.True(t, errors.As(Untar(t.TempDir(), archive, &Options{MaxTotalBytes: 1 << 20}), &se))
+	var ee *EntryLimitError
+	assert.True(t, errors.As(Untar(t.TempDir(), archive, &Options{MaxEntries: 1}), &ee))
+}
@@ -0,0 +1,55 @@
+package compress
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// walkFunc 处理目录树中的一个条目, name为条目在归档内的路径 (以'/'分隔, 目录以'/'结尾), p为条目在文件系统中的路径.
+type walkFunc func(name string, p string, info os.FileInfo) error
+
+// walk 按字典序遍历目录src, 根据opts过滤条目, 保证每次生成的归档内容一致.
+// skip为需要跳过的文件路径, 通常是正在写入的归档本身.
+func walk(src string, skip string, opts *Options, fn walkFunc) error {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if skip, err = filepath.Abs(skip); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == src || p == skip {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if info.IsDir() {
+			name += "/"
+		}
+
+		if !opts.included(name, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch opts.Symlinks {
+			case SymlinkSkip:
+				return nil
+			case SymlinkReject:
+				target, _ := os.Readlink(p)
+				return &SymlinkError{Name: name, Target: target}
+			}
+		}
+		return fn(name, p, info)
+	})
+}
@@ -0,0 +1,155 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package deepcopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amazingchow/photon-dance-golang-snippets/fwriter"
+)
+
+// ErrNotSymlink SwapSymlink模式下目标路径已存在但不是符号链接
+var ErrNotSymlink = errors.New("deepcopy: destination exists and is not a symlink")
+
+// CopyAtomic 将src拷贝到dst, 读者要么看到完整的旧目录, 要么看到完整的新目录, opts为nil时使用默认配置.
+// 新目录先被拷贝到dst所在目录下的临时目录并落盘, 再按opts.Swap原子地替换到dst.
+// 替换期间持有文件锁dst.lock, 对同一目标的并发调用 (包括其他进程中的调用) 依次进行.
+// 保留的旧目录被重命名为"dst.old.<纳秒时间戳>" (SwapExchange) 或维持其带版本号的目录名 (SwapSymlink).
+func CopyAtomic(dst, src string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	dst = filepath.Clean(dst)
+	parent := filepath.Dir(dst)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	flock := fwriter.NewFLock(dst)
+	if err := flock.AcquireContext(context.Background()); err != nil {
+		return err
+	}
+	defer flock.Release() // nolint
+
+	switch opts.Swap {
+	case SwapExchange:
+		return swapExchange(dst, src, opts)
+	case SwapSymlink:
+		return swapSymlink(dst, src, opts)
+	default:
+		return fmt.Errorf("deepcopy: unknown swap mode %d", opts.Swap)
+	}
+}
+
+func swapExchange(dst, src string, opts *Options) error {
+	parent, base := filepath.Split(dst)
+	tmp, err := ioutil.TempDir(parent, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // nolint
+	if err = copyDurable(tmp, src, opts); err != nil {
+		return err
+	}
+
+	if _, err = os.Lstat(dst); os.IsNotExist(err) {
+		if err = os.Rename(tmp, dst); err != nil {
+			return err
+		}
+		return syncDir(parent)
+	}
+	if err = exchange(tmp, dst); err != nil {
+		return err
+	}
+	if err = syncDir(parent); err != nil {
+		return err
+	}
+	// 交换后tmp为旧目录
+	if opts.KeepOld {
+		if err = os.Rename(tmp, fmt.Sprintf("%s.old.%d", dst, time.Now().UnixNano())); err != nil {
+			return err
+		}
+		return syncDir(parent)
+	}
+	return nil
+}
+
+func swapSymlink(dst, src string, opts *Options) error {
+	parent, base := filepath.Split(dst)
+	old := ""
+	if fi, err := os.Lstat(dst); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return ErrNotSymlink
+		}
+		if old, err = os.Readlink(dst); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	version := fmt.Sprintf(".%s.%d", base, time.Now().UnixNano())
+	target := filepath.Join(parent, version)
+	if err := copyDurable(target, src, opts); err != nil {
+		os.RemoveAll(target) // nolint
+		return err
+	}
+
+	link := filepath.Join(parent, version+".link")
+	if err := os.Symlink(version, link); err != nil {
+		os.RemoveAll(target) // nolint
+		return err
+	}
+	if err := os.Rename(link, dst); err != nil {
+		os.Remove(link)      // nolint
+		os.RemoveAll(target) // nolint
+		return err
+	}
+	if err := syncDir(parent); err != nil {
+		return err
+	}
+
+	// 仅删除由CopyAtomic创建的旧版本目录
+	if old == "" || opts.KeepOld || strings.ContainsRune(old, os.PathSeparator) || !strings.HasPrefix(old, "."+base+".") {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(parent, old))
+}
+
+// copyDurable 拷贝目录并将其中所有文件和目录落盘.
+func copyDurable(dst, src string, opts *Options) error {
+	if err := CopyWithOptions(dst, src, opts); err != nil {
+		return err
+	}
+	return filepath.Walk(dst, func(fn string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		fd, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		return fd.Sync()
+	})
+}
+
+func syncDir(dir string) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return fd.Sync()
+}
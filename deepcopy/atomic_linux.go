@@ -0,0 +1,32 @@
+package deepcopy
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const renameExchange = 0x2
+
+// exchange 通过renameat2(RENAME_EXCHANGE)原子地交换两个路径.
+func exchange(oldpath, newpath string) error {
+	trap := sysRenameat2
+	if trap < 0 {
+		return &os.LinkError{Op: "renameat2", Old: oldpath, New: newpath, Err: syscall.ENOSYS}
+	}
+	p0, err := syscall.BytePtrFromString(oldpath)
+	if err != nil {
+		return err
+	}
+	p1, err := syscall.BytePtrFromString(newpath)
+	if err != nil {
+		return err
+	}
+	dirfd := atFdcwd
+	_, _, errno := syscall.Syscall6(uintptr(trap), uintptr(dirfd), uintptr(unsafe.Pointer(p0)),
+		uintptr(dirfd), uintptr(unsafe.Pointer(p1)), renameExchange, 0)
+	if errno != 0 {
+		return &os.LinkError{Op: "renameat2", Old: oldpath, New: newpath, Err: errno}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package deepcopy
+
+import (
+	"errors"
+	"os"
+)
+
+// exchange 非Linux平台不支持原子交换, 请使用SwapSymlink.
+func exchange(oldpath, newpath string) error {
+	return &os.LinkError{Op: "exchange", Old: oldpath, New: newpath, Err: errors.New("not supported")}
+}
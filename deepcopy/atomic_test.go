@@ -0,0 +1,137 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package deepcopy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/amazingchow/photon-dance-golang-snippets/fwriter"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFile(t *testing.T, fn string) string {
+	content, err := ioutil.ReadFile(fn)
+	assert.Empty(t, err)
+	return string(content)
+}
+
+// siblings 返回dir下除name及其锁文件以外的条目.
+func siblings(t *testing.T, dir, name string) []string {
+	fInfos, err := ioutil.ReadDir(dir)
+	assert.Empty(t, err)
+	var names []string
+	for _, fi := range fInfos {
+		if fi.Name() != name && fi.Name() != name+".lock" {
+			names = append(names, fi.Name())
+		}
+	}
+	return names
+}
+
+func TestCopyAtomicExchange(t *testing.T) {
+	v1, v2 := t.TempDir(), t.TempDir()
+	writeTree(t, v1, map[string]string{"app.yaml": "v1", "old.yaml": "old"})
+	writeTree(t, v2, map[string]string{"app.yaml": "v2"})
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "conf")
+
+	assert.Empty(t, CopyAtomic(dst, v1, nil))
+	assert.Equal(t, "v1", readFile(t, filepath.Join(dst, "app.yaml")))
+
+	err := CopyAtomic(dst, v2, nil)
+	if le, ok := err.(*os.LinkError); ok && (le.Err == syscall.EINVAL || le.Err == syscall.ENOSYS) {
+		t.Skipf("renameat2 is not supported: %v", err)
+	}
+	assert.Empty(t, err)
+	assert.Equal(t, "v2", readFile(t, filepath.Join(dst, "app.yaml")))
+	_, err = os.Stat(filepath.Join(dst, "old.yaml"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Empty(t, siblings(t, parent, "conf"))
+
+	assert.Empty(t, CopyAtomic(dst, v1, &Options{KeepOld: true}))
+	assert.Equal(t, "v1", readFile(t, filepath.Join(dst, "app.yaml")))
+	names := siblings(t, parent, "conf")
+	assert.Len(t, names, 1)
+	assert.True(t, strings.HasPrefix(names[0], "conf.old."))
+	assert.Equal(t, "v2", readFile(t, filepath.Join(parent, names[0], "app.yaml")))
+}
+
+func TestCopyAtomicSymlink(t *testing.T) {
+	v1, v2 := t.TempDir(), t.TempDir()
+	writeTree(t, v1, map[string]string{"app.yaml": "v1"})
+	writeTree(t, v2, map[string]string{"app.yaml": "v2"})
+	parent := t.TempDir()
+	dst := filepath.Join(parent, "conf")
+	opts := &Options{Swap: SwapSymlink}
+
+	assert.Empty(t, CopyAtomic(dst, v1, opts))
+	assert.Empty(t, CopyAtomic(dst, v2, opts))
+	assert.Equal(t, "v2", readFile(t, filepath.Join(dst, "app.yaml")))
+	fi, err := os.Lstat(dst)
+	assert.Empty(t, err)
+	assert.True(t, fi.Mode()&os.ModeSymlink != 0)
+	// 旧版本目录已被删除
+	assert.Len(t, siblings(t, parent, "conf"), 1)
+
+	opts.KeepOld = true
+	assert.Empty(t, CopyAtomic(dst, v1, opts))
+	assert.Equal(t, "v1", readFile(t, filepath.Join(dst, "app.yaml")))
+	assert.Len(t, siblings(t, parent, "conf"), 2)
+
+	plain := filepath.Join(parent, "plain")
+	assert.Empty(t, os.Mkdir(plain, 0755))
+	assert.Equal(t, ErrNotSymlink, CopyAtomic(plain, v1, opts))
+}
+
+func TestCopyAtomicLocked(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"app.yaml": "v1"})
+	dst := filepath.Join(t.TempDir(), "conf")
+
+	// 锁被占用时等待其释放, 而不是直接失败
+	flock := fwriter.NewFLock(dst)
+	assert.Empty(t, flock.Acquire())
+	done := make(chan error)
+	opts := &Options{Swap: SwapSymlink}
+	go func() {
+		done <- CopyAtomic(dst, src, opts)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("CopyAtomic returned %v while locked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, flock.Release())
+	assert.Empty(t, <-done)
+	assert.Equal(t, "v1", readFile(t, filepath.Join(dst, "app.yaml")))
+}
+
+func TestCopyAtomicConcurrent(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"app.yaml": "v1"})
+	dst := filepath.Join(t.TempDir(), "conf")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- CopyAtomic(dst, src, &Options{Swap: SwapSymlink})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Empty(t, err)
+	}
+	assert.Equal(t, "v1", readFile(t, filepath.Join(dst, "app.yaml")))
+}
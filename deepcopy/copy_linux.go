@@ -0,0 +1,92 @@
+package deepcopy
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// copyContents 将src的内容拷贝到dst, 返回拷贝的逻辑字节数.
+// 文件系统支持reflink (btrfs、xfs等) 时通过FICLONE共享数据块, 否则按数据段拷贝并跳过空洞以保留稀疏文件.
+// 数据段的拷贝由os.File.ReadFrom完成, 内核支持时使用copy_file_range, 否则回退到用户态拷贝.
+func copyContents(dst, src *os.File, size int64) (int64, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	if reflink(dst, src) == nil {
+		return size, nil
+	}
+
+	var off int64
+	for off < size {
+		start, err := src.Seek(off, seekData)
+		if err != nil {
+			if isErrno(err, syscall.ENXIO) {
+				// 其后全为空洞
+				break
+			}
+			if !isErrno(err, syscall.EINVAL) {
+				return off, err
+			}
+			// 文件系统不支持SEEK_DATA, 将剩余部分视为数据
+			start = off
+		}
+		end, err := src.Seek(start, seekHole)
+		if err != nil {
+			if !isErrno(err, syscall.EINVAL) {
+				return off, err
+			}
+			end = size
+		}
+		if end > size {
+			end = size
+		}
+		if err = copyRange(dst, src, start, end-start); err != nil {
+			return off, err
+		}
+		off = end
+	}
+	// 截断到原始大小, 以保留文件末尾的空洞
+	if err := dst.Truncate(size); err != nil {
+		return off, err
+	}
+	return size, nil
+}
+
+// reflink 通过FICLONE使dst共享src的数据块, 文件系统不支持时返回错误.
+func reflink(dst, src *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd()); errno != 0 {
+		return &os.PathError{Op: "ioctl FICLONE", Path: dst.Name(), Err: errno}
+	}
+	return nil
+}
+
+// copyRange 将src中[off, off+n)的数据拷贝到dst的相同位置.
+func copyRange(dst, src *os.File, off, n int64) error {
+	if _, err := src.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := dst.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	written, err := io.Copy(dst, &io.LimitedReader{R: src, N: n})
+	if err != nil {
+		return err
+	}
+	if written != n {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == errno
+}
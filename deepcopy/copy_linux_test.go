@@ -0,0 +1,209 @@
+package deepcopy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSparse 创建大小为size的稀疏文件, 仅在头部和中间位置写入数据.
+func writeSparse(t testing.TB, fn string, size int64) []byte {
+	fd, err := os.Create(fn)
+	assert.Empty(t, err)
+	defer fd.Close()
+	assert.Empty(t, fd.Truncate(size))
+	data := bytes.Repeat([]byte("checkpoint"), 4096)
+	for _, off := range []int64{0, size / 2} {
+		_, err = fd.WriteAt(data, off)
+		assert.Empty(t, err)
+	}
+	return data
+}
+
+func allocated(t testing.TB, fn string) int64 {
+	fi, err := os.Stat(fn)
+	assert.Empty(t, err)
+	return fi.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestCopySparseFile(t *testing.T) {
+	src := t.TempDir()
+	size := int64(64 << 20)
+	data := writeSparse(t, filepath.Join(src, "model.ckpt"), size)
+	if allocated(t, filepath.Join(src, "model.ckpt")) >= size {
+		t.Skip("filesystem does not support sparse files")
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	summary, err := CopyTree(dst, src, nil)
+	assert.Empty(t, err)
+	assert.Equal(t, size, summary.Bytes)
+
+	fn := filepath.Join(dst, "model.ckpt")
+	fi, err := os.Stat(fn)
+	assert.Empty(t, err)
+	assert.Equal(t, size, fi.Size())
+	assert.Less(t, allocated(t, fn), size/2)
+
+	content, err := ioutil.ReadFile(fn)
+	assert.Empty(t, err)
+	assert.Equal(t, data, content[:len(data)])
+	assert.Equal(t, data, content[size/2:size/2+int64(len(data))])
+	assert.Equal(t, make([]byte, size/2-int64(len(data))), content[len(data):size/2])
+	assert.Equal(t, make([]byte, size/2-int64(len(data))), content[size/2+int64(len(data)):])
+}
+
+func TestCopyContentsTrailingData(t *testing.T) {
+	// 非稀疏文件同样按数据段拷贝
+	src := filepath.Join(t.TempDir(), "src")
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	assert.Empty(t, ioutil.WriteFile(src, data, 0644))
+	dst := filepath.Join(t.TempDir(), "dst")
+	n, err := fcopy(dst, src, 0600)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	content, err := ioutil.ReadFile(dst)
+	assert.Empty(t, err)
+	assert.Equal(t, data, content)
+	fi, err := os.Stat(dst)
+	assert.Empty(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+// mountScratch 在临时目录下挂载一个fstype文件系统并返回挂载点, 没有root权限或缺少工具时跳过.
+// tmpfs直接挂载, 其他文件系统格式化一个稀疏的镜像文件后以loop方式挂载.
+func mountScratch(tb testing.TB, fstype string) string {
+	if os.Geteuid() != 0 {
+		tb.Skip("mounting a scratch filesystem requires root")
+	}
+	dir := tb.TempDir()
+	mnt := filepath.Join(dir, "mnt")
+	assert.Empty(tb, os.Mkdir(mnt, 0755))
+
+	args := []string{"-t", "tmpfs", "-o", "size=512m", "tmpfs", mnt}
+	if fstype != "tmpfs" {
+		mkfs, err := exec.LookPath("mkfs." + fstype)
+		if err != nil {
+			tb.Skipf("mkfs.%s is not installed", fstype)
+		}
+		img := filepath.Join(dir, fstype+".img")
+		fd, err := os.Create(img)
+		assert.Empty(tb, err)
+		assert.Empty(tb, fd.Truncate(512<<20))
+		assert.Empty(tb, fd.Close())
+		if out, err := exec.Command(mkfs, append(__MkfsArgs[fstype], img)...).CombinedOutput(); err != nil {
+			tb.Skipf("mkfs.%s: %v: %s", fstype, err, out)
+		}
+		args = []string{"-t", fstype, "-o", "loop", img, mnt}
+	}
+	if out, err := exec.Command("mount", args...).CombinedOutput(); err != nil {
+		tb.Skipf("mount %s: %v: %s", fstype, err, out)
+	}
+	// 在TempDir删除目录之前卸载
+	tb.Cleanup(func() { exec.Command("umount", mnt).Run() }) // nolint
+	return mnt
+}
+
+var __MkfsArgs = map[string][]string{
+	"ext4":  {"-q", "-F"},
+	"xfs":   {"-q", "-f", "-m", "reflink=1"},
+	"btrfs": {"-q", "-f"},
+}
+
+func TestCopyReflink(t *testing.T) {
+	for _, fstype := range []string{"xfs", "btrfs"} {
+		t.Run(fstype, func(t *testing.T) {
+			dir := mountScratch(t, fstype)
+			src := filepath.Join(dir, "src")
+			data := bytes.Repeat([]byte("checkpoint"), 1<<20)
+			assert.Empty(t, ioutil.WriteFile(src, data, 0644))
+
+			srcFd, err := os.Open(src)
+			assert.Empty(t, err)
+			defer srcFd.Close()
+			dstFd, err := os.Create(filepath.Join(dir, "clone"))
+			assert.Empty(t, err)
+			defer dstFd.Close()
+			assert.Empty(t, reflink(dstFd, srcFd))
+
+			dst := filepath.Join(dir, "dst")
+			n, err := fcopy(dst, src, 0644)
+			assert.Empty(t, err)
+			assert.Equal(t, int64(len(data)), n)
+			for _, fn := range []string{dstFd.Name(), dst} {
+				content, err := ioutil.ReadFile(fn)
+				assert.Empty(t, err)
+				assert.Equal(t, data, content, fn)
+			}
+		})
+	}
+}
+
+func benchmarkCopy(b *testing.B, dir string, sparse bool, copyFn func(dst, src string) error) {
+	src := filepath.Join(dir, "src")
+	size := int64(64 << 20)
+	if sparse {
+		writeSparse(b, src, size)
+	} else {
+		assert.Empty(b, ioutil.WriteFile(src, bytes.Repeat([]byte("checkpoint"), int(size/10)), 0644))
+	}
+	dst := filepath.Join(dir, "dst")
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := copyFn(dst, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(allocated(b, dst)), "allocated-bytes")
+}
+
+func fcopyFn(dst, src string) error {
+	_, err := fcopy(dst, src, 0644)
+	return err
+}
+
+// userspaceCopy 经由用户态缓冲区拷贝, 作为对照组
+func userspaceCopy(dst, src string) error {
+	srcFd, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFd.Close()
+	dstFd, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFd.Close()
+	_, err = io.CopyBuffer(struct{ io.Writer }{dstFd}, struct{ io.Reader }{srcFd}, make([]byte, 32<<10))
+	return err
+}
+
+// benchmarkFilesystems 依次在tmpfs和以loop方式挂载的ext4、xfs、btrfs上运行基准测试,
+// xfs和btrfs支持reflink, fcopy在其上走FICLONE路径.
+func benchmarkFilesystems(b *testing.B, copyFn func(dst, src string) error) {
+	for _, fstype := range []string{"tmpfs", "ext4", "xfs", "btrfs"} {
+		b.Run(fstype, func(b *testing.B) {
+			dir := mountScratch(b, fstype)
+			b.Run("dense", func(b *testing.B) { benchmarkCopy(b, dir, false, copyFn) })
+			b.Run("sparse", func(b *testing.B) { benchmarkCopy(b, dir, true, copyFn) })
+		})
+	}
+}
+
+func BenchmarkFcopyDense(b *testing.B)          { benchmarkCopy(b, b.TempDir(), false, fcopyFn) }
+func BenchmarkFcopySparse(b *testing.B)         { benchmarkCopy(b, b.TempDir(), true, fcopyFn) }
+func BenchmarkUserspaceCopyDense(b *testing.B)  { benchmarkCopy(b, b.TempDir(), false, userspaceCopy) }
+func BenchmarkUserspaceCopySparse(b *testing.B) { benchmarkCopy(b, b.TempDir(), true, userspaceCopy) }
+func BenchmarkFcopyFilesystems(b *testing.B)    { benchmarkFilesystems(b, fcopyFn) }
+func BenchmarkUserspaceCopyFilesystems(b *testing.B) {
+	benchmarkFilesystems(b, userspaceCopy)
+}
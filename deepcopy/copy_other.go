@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package deepcopy
+
+import (
+	"io"
+	"os"
+)
+
+// copyContents 将src的内容拷贝到dst, 返回拷贝的逻辑字节数.
+func copyContents(dst, src *os.File, size int64) (int64, error) {
+	return io.Copy(dst, src)
+}
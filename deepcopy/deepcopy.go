@@ -1,58 +1,391 @@
 package deepcopy
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 )
 
-func fcopy(dst, src string) error {
+// CycleError 跟随符号链接时出现了环路.
+type CycleError struct {
+	Path string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("symlink cycle detected at %q", e.Path)
+}
+
+// SpecialFileError 遇到了设备文件、管道、套接字等特殊文件.
+type SpecialFileError struct {
+	Path string
+	Mode os.FileMode
+}
+
+func (e *SpecialFileError) Error() string {
+	return fmt.Sprintf("special file %q (%s) can not be copied", e.Path, e.Mode.Type())
+}
+
+// Summary 拷贝结果统计
+type Summary struct {
+	Copied  int64 // 拷贝的文件和符号链接数
+	Skipped int64 // 增量拷贝时因已是最新而跳过的文件数
+	Deleted int64 // 从目标目录删除的条目数, 删除的目录只计一次
+	Bytes   int64 // 拷贝的字节数
+}
+
+// fileID 唯一标识一个文件
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// fileMode 返回需要保留的权限位, 包括setuid、setgid和sticky位.
+func fileMode(info os.FileInfo) os.FileMode {
+	return info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// dirEntry 待设置元数据的目录
+type dirEntry struct {
+	dst  string
+	src  string
+	info os.FileInfo
+}
+
+type copier struct {
+	opts      *Options
+	ancestors map[fileID]struct{} // 当前路径上的所有目录, 用于识别环路
+	dirs      []dirEntry          // 按先序遍历的顺序记录的目录
+	summary   Summary
+
+	jobs chan func() error
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	err  error
+}
+
+func fcopy(dst, src string, mode os.FileMode) (int64, error) {
 	srcFd, err := os.Open(src)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer srcFd.Close()
 	dstfd, err := os.Create(dst)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer dstfd.Close()
-	if _, err = io.Copy(dstfd, srcFd); err != nil {
-		return err
+	fi, err := srcFd.Stat()
+	if err != nil {
+		return 0, err
 	}
-	srcInfo, err := os.Stat(src)
+	n, err := copyContents(dstfd, srcFd, fi.Size())
 	if err != nil {
-		return err
+		return n, err
 	}
-	return os.Chmod(dst, srcInfo.Mode())
+	return n, os.Chmod(dst, mode)
 }
 
-// Copy copies a whole directory recursively.
+// Copy 使用默认配置递归地拷贝整个目录.
 func Copy(dst, src string) error {
+	return CopyWithOptions(dst, src, nil)
+}
+
+// CopyWithOptions 递归地拷贝整个目录, opts为nil时使用默认配置.
+func CopyWithOptions(dst, src string, opts *Options) error {
+	_, err := CopyTree(dst, src, opts)
+	return err
+}
+
+// CopyTree 与CopyWithOptions相同, 同时返回拷贝结果统计.
+// opts.Concurrency大于1时文件由多个协程并行拷贝, 目录的元数据在其下所有文件拷贝完成后再设置.
+func CopyTree(dst, src string, opts *Options) (*Summary, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
 	fInfo, err := os.Stat(src)
 	if err != nil {
+		return nil, err
+	}
+	c := &copier{
+		opts:      opts,
+		ancestors: make(map[fileID]struct{}),
+	}
+	if opts.Concurrency > 1 {
+		c.jobs = make(chan func() error)
+		for i := 0; i < opts.Concurrency; i++ {
+			go c.worker()
+		}
+	}
+
+	err = c.copyDir(dst, src, fInfo)
+	if c.jobs != nil {
+		close(c.jobs)
+		c.wg.Wait()
+	}
+	if err == nil {
+		err = c.loadErr()
+	}
+	if err == nil {
+		// 子目录先于父目录设置元数据
+		for i := len(c.dirs) - 1; i >= 0; i-- {
+			d := c.dirs[i]
+			if err = os.Chmod(d.dst, fileMode(d.info)); err != nil {
+				break
+			}
+			if err = c.preserve(d.dst, d.src, d.info, false); err != nil {
+				break
+			}
+		}
+	}
+	summary := c.summary
+	return &summary, err
+}
+
+func (c *copier) worker() {
+	for job := range c.jobs {
+		if c.loadErr() == nil {
+			if err := job(); err != nil {
+				c.storeErr(err)
+			}
+		}
+		c.wg.Done()
+	}
+}
+
+// submit 提交文件拷贝任务, 串行拷贝时直接执行.
+func (c *copier) submit(job func() error) error {
+	if c.jobs == nil {
+		return job()
+	}
+	c.wg.Add(1)
+	c.jobs <- job
+	return c.loadErr()
+}
+
+func (c *copier) loadErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *copier) storeErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *copier) copyDir(dst, src string, info os.FileInfo) error {
+	if id, ok := fileIDOf(info); ok {
+		if _, found := c.ancestors[id]; found {
+			return &CycleError{Path: src}
+		}
+		c.ancestors[id] = struct{}{}
+		defer delete(c.ancestors, id)
+	}
+
+	if err := c.removeMismatched(dst, true); err != nil {
 		return err
 	}
-	if err = os.MkdirAll(dst, fInfo.Mode()); err != nil {
+	if err := os.MkdirAll(dst, info.Mode().Perm()|0700); err != nil {
 		return err
 	}
+	c.dirs = append(c.dirs, dirEntry{dst: dst, src: src, info: info})
+
 	fInfos, err := ioutil.ReadDir(src)
 	if err != nil {
 		return err
 	}
-	for _, info := range fInfos {
-		srcFp := path.Join(src, info.Name())
-		dstFp := path.Join(dst, info.Name())
-		if info.IsDir() {
-			if err = Copy(dstFp, srcFp); err != nil {
-				return err
-			}
-		} else {
-			if err = fcopy(dstFp, srcFp); err != nil {
+	for _, fi := range fInfos {
+		if err = c.copyEntry(path.Join(dst, fi.Name()), path.Join(src, fi.Name()), fi); err != nil {
+			return err
+		}
+	}
+	if c.opts.Delete {
+		return c.deleteExtraneous(dst, fInfos)
+	}
+	return nil
+}
+
+// copyEntry 拷贝目录下的一个条目, info为条目的Lstat信息.
+func (c *copier) copyEntry(dst, src string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch c.opts.Symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkPreserve:
+			return c.copySymlink(dst, src, info)
+		}
+		var err error
+		if info, err = os.Stat(src); err != nil {
+			return err
+		}
+	}
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		return c.copyDir(dst, src, info)
+	case mode.IsRegular():
+		return c.submit(func() error {
+			return c.copyFile(dst, src, info)
+		})
+	default:
+		if c.opts.Specials == SpecialError {
+			return &SpecialFileError{Path: src, Mode: mode}
+		}
+		return nil
+	}
+}
+
+func (c *copier) copyFile(dst, src string, info os.FileInfo) error {
+	skip, err := c.upToDate(dst, src, info)
+	if err != nil {
+		return err
+	}
+	if skip {
+		atomic.AddInt64(&c.summary.Skipped, 1)
+		if err = os.Chmod(dst, fileMode(info)); err != nil {
+			return err
+		}
+		return c.preserve(dst, src, info, false)
+	}
+
+	if err = c.removeMismatched(dst, false); err != nil {
+		return err
+	}
+	n, err := fcopy(dst, src, fileMode(info))
+	atomic.AddInt64(&c.summary.Bytes, n)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&c.summary.Copied, 1)
+	return c.preserve(dst, src, info, false)
+}
+
+// upToDate 增量拷贝时判断dst是否已与src一致.
+func (c *copier) upToDate(dst, src string, info os.FileInfo) (bool, error) {
+	if c.opts.Compare == CompareNone {
+		return false, nil
+	}
+	dInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !dInfo.Mode().IsRegular() || dInfo.Size() != info.Size() {
+		return false, nil
+	}
+	if c.opts.Compare == CompareSizeAndTime {
+		return dInfo.ModTime().Equal(info.ModTime()), nil
+	}
+
+	srcSum, err := checksum(src)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := checksum(dst)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(srcSum, dstSum), nil
+}
+
+func checksum(fn string) ([]byte, error) {
+	fd, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, fd); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// removeMismatched 删除dst处类型不一致的条目, 如源为目录而目标为普通文件.
+func (c *copier) removeMismatched(dst string, dir bool) error {
+	dInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dir == dInfo.IsDir() && (dir || dInfo.Mode().IsRegular()) {
+		return nil
+	}
+	return os.RemoveAll(dst)
+}
+
+// deleteExtraneous 删除目录dst下源目录中不存在的条目.
+func (c *copier) deleteExtraneous(dst string, srcInfos []os.FileInfo) error {
+	names := make(map[string]struct{}, len(srcInfos))
+	for _, fi := range srcInfos {
+		names[fi.Name()] = struct{}{}
+	}
+	dstInfos, err := ioutil.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, fi := range dstInfos {
+		if _, ok := names[fi.Name()]; ok {
+			continue
+		}
+		if err = os.RemoveAll(path.Join(dst, fi.Name())); err != nil {
+			return err
+		}
+		atomic.AddInt64(&c.summary.Deleted, 1)
+	}
+	return nil
+}
+
+func (c *copier) copySymlink(dst, src string, info os.FileInfo) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Lstat(dst); err == nil {
+		if err = os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err = os.Symlink(target, dst); err != nil {
+		return err
+	}
+	atomic.AddInt64(&c.summary.Copied, 1)
+	return c.preserve(dst, src, info, true)
+}
+
+// preserve 按配置将src的属主、扩展属性和时间戳保留到dst, link表示dst为符号链接本身.
+func (c *copier) preserve(dst, src string, info os.FileInfo, link bool) error {
+	if c.opts.PreserveOwner {
+		if err := lchown(dst, info); err != nil {
+			return err
+		}
+		// 修改属主会清除setuid/setgid位, 需要重新设置权限
+		if !link {
+			if err := os.Chmod(dst, fileMode(info)); err != nil {
 				return err
 			}
 		}
 	}
+	if c.opts.PreserveXattrs && !link {
+		if err := copyXattrs(dst, src); err != nil {
+			return err
+		}
+	}
+	if c.opts.PreserveTimes || c.opts.Compare == CompareSizeAndTime {
+		return setTimes(dst, info, link)
+	}
 	return nil
 }
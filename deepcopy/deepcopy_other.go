@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package deepcopy
+
+import "os"
+
+// fileIDOf 非Unix平台无法唯一标识文件, 跟随符号链接时不检测环路.
+func fileIDOf(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
+
+// lchown 非Unix平台不支持保留属主.
+func lchown(dst string, info os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package deepcopy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		assert.Empty(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Empty(t, ioutil.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{
+		"conf/app.yaml":  "app",
+		"conf/db/a.yaml": "db",
+	})
+	assert.Empty(t, os.Chmod(filepath.Join(src, "conf/app.yaml"), 0600))
+	assert.Empty(t, os.Symlink("conf/app.yaml", filepath.Join(src, "current")))
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, Copy(dst, src))
+	content, err := ioutil.ReadFile(filepath.Join(dst, "conf/db/a.yaml"))
+	assert.Empty(t, err)
+	assert.Equal(t, "db", string(content))
+	fi, err := os.Lstat(filepath.Join(dst, "current"))
+	assert.Empty(t, err)
+	assert.True(t, fi.Mode().IsRegular())
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	dst = filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, &Options{Symlinks: SymlinkPreserve}))
+	target, err := os.Readlink(filepath.Join(dst, "current"))
+	assert.Empty(t, err)
+	assert.Equal(t, "conf/app.yaml", target)
+
+	dst = filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, &Options{Symlinks: SymlinkSkip}))
+	_, err = os.Lstat(filepath.Join(dst, "current"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCopySymlinkCycle(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"sub/file": "file"})
+	assert.Empty(t, os.Symlink("..", filepath.Join(src, "sub/parent")))
+
+	var e *CycleError
+	assert.True(t, errors.As(CopyWithOptions(filepath.Join(t.TempDir(), "copy"), src, nil), &e))
+	assert.Empty(t, CopyWithOptions(filepath.Join(t.TempDir(), "copy"), src, &Options{Symlinks: SymlinkPreserve}))
+}
+
+func TestCopyPreserveTimes(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"sub/file": "file"})
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Empty(t, os.Chtimes(filepath.Join(src, "sub/file"), mtime, mtime))
+	assert.Empty(t, os.Chtimes(filepath.Join(src, "sub"), mtime, mtime))
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, &Options{PreserveTimes: true, PreserveOwner: os.Geteuid() == 0}))
+	for _, name := range []string{"sub/file", "sub"} {
+		fi, err := os.Stat(filepath.Join(dst, name))
+		assert.Empty(t, err)
+		assert.True(t, mtime.Equal(fi.ModTime()), name)
+	}
+}
+
+func TestCopyTreeIncremental(t *testing.T) {
+	src := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 64; i++ {
+		files[fmt.Sprintf("shard-%d/part-%03d", i%4, i)] = fmt.Sprintf("content-%03d", i)
+	}
+	writeTree(t, src, files)
+	dst := filepath.Join(t.TempDir(), "copy")
+
+	opts := &Options{Concurrency: 8, Compare: CompareSizeAndTime, Delete: true}
+	summary, err := CopyTree(dst, src, opts)
+	assert.Empty(t, err)
+	assert.Equal(t, Summary{Copied: 64, Bytes: 64 * 11}, *summary)
+
+	summary, err = CopyTree(dst, src, opts)
+	assert.Empty(t, err)
+	assert.Equal(t, Summary{Skipped: 64}, *summary)
+
+	// 修改一个文件, 并在目标目录中添加多余的条目
+	writeTree(t, src, map[string]string{"shard-0/part-000": "modified-000"})
+	writeTree(t, dst, map[string]string{"shard-1/stale": "stale", "stale/file": "stale"})
+	summary, err = CopyTree(dst, src, opts)
+	assert.Empty(t, err)
+	assert.Equal(t, Summary{Copied: 1, Skipped: 63, Deleted: 2, Bytes: 12}, *summary)
+	for _, name := range []string{"shard-1/stale", "stale"} {
+		_, err = os.Lstat(filepath.Join(dst, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// 大小和mtime一致但内容不同时, 只有按内容比较才会重新拷贝
+	fn := filepath.Join(dst, "shard-2/part-002")
+	fi, err := os.Stat(fn)
+	assert.Empty(t, err)
+	assert.Empty(t, ioutil.WriteFile(fn, []byte("corrupt-002"), 0644))
+	assert.Empty(t, os.Chtimes(fn, fi.ModTime(), fi.ModTime()))
+	summary, err = CopyTree(dst, src, opts)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(0), summary.Copied)
+	opts.Compare = CompareContent
+	summary, err = CopyTree(dst, src, opts)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(1), summary.Copied)
+	content, err := ioutil.ReadFile(fn)
+	assert.Empty(t, err)
+	assert.Equal(t, "content-002", string(content))
+}
@@ -0,0 +1,45 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package deepcopy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopySpecialFile(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"file": "file"})
+	assert.Empty(t, syscall.Mkfifo(filepath.Join(src, "fifo"), 0644))
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, nil))
+	_, err := os.Lstat(filepath.Join(dst, "fifo"))
+	assert.True(t, os.IsNotExist(err))
+
+	var e *SpecialFileError
+	assert.True(t, errors.As(CopyWithOptions(filepath.Join(t.TempDir(), "copy"), src, &Options{Specials: SpecialError}), &e))
+}
+
+func TestCopySpecialModeBits(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"shared/run.sh": "#!/bin/sh"})
+	assert.Empty(t, os.Chmod(filepath.Join(src, "shared/run.sh"), 0755|os.ModeSetuid|os.ModeSetgid))
+	assert.Empty(t, os.Chmod(filepath.Join(src, "shared"), 0777|os.ModeSticky))
+
+	// 修改属主后setuid/setgid位仍然保留
+	dst := filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, &Options{PreserveOwner: true}))
+	fi, err := os.Stat(filepath.Join(dst, "shared/run.sh"))
+	assert.Empty(t, err)
+	assert.Equal(t, 0755|os.ModeSetuid|os.ModeSetgid, fi.Mode()&^os.ModeType)
+	fi, err = os.Stat(filepath.Join(dst, "shared"))
+	assert.Empty(t, err)
+	assert.Equal(t, 0777|os.ModeSticky, fi.Mode()&^os.ModeType)
+}
@@ -0,0 +1,8 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le || ppc64 || ppc64le)
+// +build linux
+// +build mips mipsle mips64 mips64le ppc64 ppc64le
+
+package deepcopy
+
+// mips和ppc64的_IOC_WRITE为4, 且方向位从第29位开始
+const ficlone = 0x80049409 // _IOW(0x94, 9, int)
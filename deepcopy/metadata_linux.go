@@ -0,0 +1,86 @@
+package deepcopy
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	atFdcwd           = -0x64
+	atSymlinkNofollow = 0x100
+)
+
+// setTimes 将dst的atime和mtime设置为与info一致, link为true时修改符号链接本身.
+func setTimes(dst string, info os.FileInfo, link bool) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	p, err := syscall.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	ts := [2]syscall.Timespec{st.Atim, st.Mtim}
+	dirfd, flags := atFdcwd, 0
+	if link {
+		flags = atSymlinkNofollow
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&ts[0])), uintptr(flags), 0, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "utimensat", Path: dst, Err: errno}
+	}
+	return nil
+}
+
+// copyXattrs 将src的扩展属性拷贝到dst.
+func copyXattrs(dst, src string) error {
+	sz, err := syscall.Listxattr(src, nil)
+	if err != nil {
+		if err == syscall.ENOTSUP {
+			return nil
+		}
+		return &os.PathError{Op: "listxattr", Path: src, Err: err}
+	}
+	if sz == 0 {
+		return nil
+	}
+	names := make([]byte, sz)
+	if sz, err = syscall.Listxattr(src, names); err != nil {
+		return &os.PathError{Op: "listxattr", Path: src, Err: err}
+	}
+	for _, name := range strings.Split(strings.TrimRight(string(names[:sz]), "\x00"), "\x00") {
+		vsz, err := syscall.Getxattr(src, name, nil)
+		if err != nil {
+			if privilegedXattr(name, err) {
+				continue
+			}
+			return &os.PathError{Op: "getxattr", Path: src, Err: err}
+		}
+		value := make([]byte, vsz)
+		if vsz, err = syscall.Getxattr(src, name, value); err != nil {
+			if privilegedXattr(name, err) {
+				continue
+			}
+			return &os.PathError{Op: "getxattr", Path: src, Err: err}
+		}
+		if err = syscall.Setxattr(dst, name, value[:vsz], 0); err != nil {
+			if privilegedXattr(name, err) {
+				continue
+			}
+			return &os.PathError{Op: "setxattr", Path: dst, Err: err}
+		}
+	}
+	return nil
+}
+
+// privilegedXattr 判断是否因缺少权限而无法读写security.*或trusted.*命名空间的扩展属性,
+// 这两个命名空间通常需要CAP_SYS_ADMIN, 非root用户拷贝时跳过而不是令整个拷贝失败.
+func privilegedXattr(name string, err error) bool {
+	if err != syscall.EPERM && err != syscall.EACCES {
+		return false
+	}
+	return strings.HasPrefix(name, "security.") || strings.HasPrefix(name, "trusted.")
+}
@@ -0,0 +1,24 @@
+package deepcopy
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyPreserveXattrs(t *testing.T) {
+	src := t.TempDir()
+	writeTree(t, src, map[string]string{"file": "file"})
+	if err := syscall.Setxattr(filepath.Join(src, "file"), "user.checksum", []byte("abc"), 0); err != nil {
+		t.Skipf("xattr is not supported: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy")
+	assert.Empty(t, CopyWithOptions(dst, src, &Options{PreserveXattrs: true}))
+	value := make([]byte, 16)
+	n, err := syscall.Getxattr(filepath.Join(dst, "file"), "user.checksum", value)
+	assert.Empty(t, err)
+	assert.Equal(t, "abc", string(value[:n]))
+}
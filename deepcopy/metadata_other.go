@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package deepcopy
+
+import "os"
+
+// setTimes 将dst的mtime设置为与info一致, 非Linux平台atime同样使用mtime, 且不支持修改符号链接本身.
+func setTimes(dst string, info os.FileInfo, link bool) error {
+	if link {
+		return nil
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// copyXattrs 非Linux平台不支持扩展属性.
+func copyXattrs(dst, src string) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package deepcopy
+
+// SymlinkAction 符号链接的处理方式
+type SymlinkAction int
+
+const (
+	// SymlinkFollow 拷贝符号链接指向的内容, 指向祖先目录的链接会被识别为环路并报错
+	SymlinkFollow SymlinkAction = iota
+	// SymlinkPreserve 拷贝符号链接本身
+	SymlinkPreserve
+	// SymlinkSkip 忽略符号链接
+	SymlinkSkip
+)
+
+// SpecialAction 设备文件、管道、套接字等特殊文件的处理方式
+type SpecialAction int
+
+const (
+	// SpecialSkip 忽略特殊文件
+	SpecialSkip SpecialAction = iota
+	// SpecialError 遇到特殊文件直接报错
+	SpecialError
+)
+
+// CompareMode 增量拷贝时判断目标文件是否已是最新的方式
+type CompareMode int
+
+const (
+	// CompareNone 总是拷贝
+	CompareNone CompareMode = iota
+	// CompareSizeAndTime 大小和mtime都一致时跳过, 该模式下总会保留mtime
+	CompareSizeAndTime
+	// CompareContent 大小和内容哈希都一致时跳过
+	CompareContent
+)
+
+// SwapMode CopyAtomic将新目录替换到目标位置的方式
+type SwapMode int
+
+const (
+	// SwapExchange 通过renameat2(RENAME_EXCHANGE)原子地交换新旧目录, 仅Linux 3.15及以上版本支持
+	SwapExchange SwapMode = iota
+	// SwapSymlink 目标路径为指向带版本号的兄弟目录的符号链接, 通过原子地替换符号链接完成切换
+	SwapSymlink
+)
+
+// Options 目录拷贝配置, 零值即为默认配置.
+type Options struct {
+	Symlinks       SymlinkAction
+	Specials       SpecialAction
+	PreserveTimes  bool // 保留atime和mtime
+	PreserveOwner  bool // 保留uid和gid, 通常需要root权限
+	PreserveXattrs bool // 保留扩展属性, 仅Linux支持
+
+	Concurrency int         // 并发拷贝文件的协程数, 不大于1时串行拷贝
+	Compare     CompareMode // 增量拷贝模式
+	Delete      bool        // 删除目标目录中源目录不存在的条目
+
+	Swap    SwapMode // CopyAtomic的替换方式
+	KeepOld bool     // CopyAtomic替换后保留旧目录, 否则将其删除
+}
@@ -0,0 +1,3 @@
+package deepcopy
+
+const sysRenameat2 = 316
@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64 && !386 && !arm
+// +build linux,!amd64,!arm64,!386,!arm
+
+package deepcopy
+
+// 其他架构暂不支持renameat2
+const sysRenameat2 = -1
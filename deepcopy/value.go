@@ -0,0 +1,187 @@
+package deepcopy
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// Copier 实现该接口的类型由DeepCopy自行完成深拷贝, 返回值的类型必须与原类型一致.
+type Copier interface {
+	DeepCopy() interface{}
+}
+
+// ValueOptions 值深拷贝配置, 零值即为默认配置.
+type ValueOptions struct {
+	// Unexported 为true时同时深拷贝结构体的非导出字段, 否则非导出字段保持零值
+	Unexported bool
+}
+
+var (
+	copierType = reflect.TypeOf((*Copier)(nil)).Elem()
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// visitKey 唯一标识一个已拷贝的指针、映射或切片
+type visitKey struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+type valueCopier struct {
+	opts    *ValueOptions
+	visited map[visitKey]reflect.Value
+}
+
+// Value 返回v的深拷贝, 等价于ValueWithOptions(v, nil).
+// 结构体的非导出字段不会被拷贝而是保持零值, 需要拷贝时使用ValueWithOptions并设置Unexported.
+func Value(v interface{}) interface{} {
+	return ValueWithOptions(v, nil)
+}
+
+// ValueWithOptions 基于反射返回v的深拷贝, opts为nil时使用默认配置.
+// 指针、映射和切片的共享与环路关系会被保留; time.Time按值拷贝; 通道、函数和unsafe.Pointer按原值共享.
+func ValueWithOptions(v interface{}, opts *ValueOptions) interface{} {
+	if v == nil {
+		return nil
+	}
+	if opts == nil {
+		opts = &ValueOptions{}
+	}
+	c := &valueCopier{
+		opts:    opts,
+		visited: make(map[visitKey]reflect.Value),
+	}
+	src := reflect.ValueOf(v)
+	dst := reflect.New(src.Type()).Elem()
+	c.copy(dst, src)
+	return dst.Interface()
+}
+
+// copy 将src深拷贝到可寻址的dst中.
+func (c *valueCopier) copy(dst, src reflect.Value) {
+	if c.hook(dst, src) {
+		return
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := visitKey{ptr: src.Pointer(), typ: src.Type()}
+		if p, ok := c.visited[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.visited[key] = p
+		c.copy(p.Elem(), src.Elem())
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		c.copy(elem, src.Elem())
+		dst.Set(elem)
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := visitKey{ptr: src.Pointer(), typ: src.Type()}
+		if m, ok := c.visited[key]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.visited[key] = m
+		t := src.Type()
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(t.Key()).Elem()
+			c.copy(k, iter.Key())
+			e := reflect.New(t.Elem()).Elem()
+			c.copy(e, iter.Value())
+			m.SetMapIndex(k, e)
+		}
+		dst.Set(m)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := visitKey{ptr: src.Pointer(), len: src.Len(), typ: src.Type()}
+		if s, ok := c.visited[key]; ok {
+			dst.Set(s)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.visited[key] = s
+		for i := 0; i < src.Len(); i++ {
+			c.copy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copy(dst.Index(i), src.Index(i))
+		}
+
+	case reflect.Struct:
+		if src.Type() == timeType {
+			dst.Set(src)
+			return
+		}
+		c.copyStruct(dst, src)
+
+	default:
+		// 基本类型按值拷贝, 通道、函数和unsafe.Pointer按原值共享
+		dst.Set(src)
+	}
+}
+
+func (c *valueCopier) copyStruct(dst, src reflect.Value) {
+	t := src.Type()
+	if c.opts.Unexported && !src.CanAddr() {
+		// 读取非导出字段需要src可寻址
+		addr := reflect.New(t).Elem()
+		addr.Set(src)
+		src = addr
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf, df := src.Field(i), dst.Field(i)
+		if t.Field(i).PkgPath != "" {
+			if !c.opts.Unexported {
+				continue
+			}
+			sf = reflect.NewAt(sf.Type(), unsafe.Pointer(sf.UnsafeAddr())).Elem()
+			df = reflect.NewAt(df.Type(), unsafe.Pointer(df.UnsafeAddr())).Elem()
+		}
+		c.copy(df, sf)
+	}
+}
+
+// hook 若src实现了Copier, 则使用其DeepCopy的结果; 返回值类型与src不一致时panic并指明类型.
+func (c *valueCopier) hook(dst, src reflect.Value) bool {
+	if src.Kind() == reflect.Interface || !src.CanInterface() || !src.Type().Implements(copierType) {
+		return false
+	}
+	if src.Kind() == reflect.Ptr && src.IsNil() {
+		return false
+	}
+	v := src.Interface().(Copier).DeepCopy()
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() != src.Type() {
+		panic(fmt.Sprintf("deepcopy: DeepCopy of %s returned %s, want the same type", src.Type(), rv.Type()))
+	}
+	dst.Set(rv)
+	return true
+}
@@ -0,0 +1,170 @@
+package deepcopy
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type node struct {
+	Name     string
+	Parent   *node
+	Children []*node
+}
+
+type record struct {
+	ID      int64
+	Tags    []string
+	Attrs   map[string]interface{}
+	Created time.Time
+	Nested  *record
+	Fixed   [2]*int
+	secret  []byte
+}
+
+type token struct {
+	Value  string
+	copies *int
+}
+
+func (t *token) DeepCopy() interface{} {
+	*t.copies++
+	return &token{Value: t.Value + "-copy", copies: t.copies}
+}
+
+func TestValue(t *testing.T) {
+	one := 1
+	src := &record{
+		ID:      1,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]interface{}{"n": 1, "list": []int{1, 2}},
+		Created: time.Date(2021, 3, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		Nested:  &record{ID: 2},
+		Fixed:   [2]*int{&one, &one},
+		secret:  []byte("secret"),
+	}
+	dst := Value(src).(*record)
+	assert.Equal(t, src.ID, dst.ID)
+	assert.Equal(t, src.Tags, dst.Tags)
+	assert.Equal(t, src.Attrs, dst.Attrs)
+	assert.True(t, src.Created.Equal(dst.Created))
+	assert.Equal(t, src.Created.Location(), dst.Created.Location())
+	assert.Equal(t, src.Nested.ID, dst.Nested.ID)
+	assert.Nil(t, dst.secret)
+
+	// 修改拷贝不影响原值
+	dst.Tags[0] = "x"
+	dst.Attrs["list"].([]int)[0] = 100
+	dst.Nested.ID = 3
+	*dst.Fixed[0] = 2
+	assert.Equal(t, "a", src.Tags[0])
+	assert.Equal(t, 1, src.Attrs["list"].([]int)[0])
+	assert.Equal(t, int64(2), src.Nested.ID)
+	assert.Equal(t, 1, one)
+	// 共享的指针在拷贝中仍然共享
+	assert.Equal(t, 2, *dst.Fixed[1])
+
+	assert.Nil(t, Value(nil))
+	assert.Equal(t, 42, Value(42))
+	assert.Equal(t, []int(nil), Value([]int(nil)))
+}
+
+func TestValueUnexported(t *testing.T) {
+	src := record{secret: []byte("secret")}
+	dst := ValueWithOptions(src, &ValueOptions{Unexported: true}).(record)
+	assert.Equal(t, "secret", string(dst.secret))
+	dst.secret[0] = 'S'
+	assert.Equal(t, "secret", string(src.secret))
+}
+
+func TestValueCycle(t *testing.T) {
+	root := &node{Name: "root"}
+	child := &node{Name: "child", Parent: root}
+	root.Children = []*node{child, child}
+
+	dst := Value(root).(*node)
+	assert.NotSame(t, root, dst)
+	assert.Len(t, dst.Children, 2)
+	assert.Same(t, dst, dst.Children[0].Parent)
+	assert.Same(t, dst.Children[0], dst.Children[1])
+	assert.NotSame(t, child, dst.Children[0])
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	mc := Value(m).(map[string]interface{})
+	mc["k"] = "v"
+	assert.Equal(t, "v", mc["self"].(map[string]interface{})["k"])
+	assert.NotContains(t, m, "k")
+}
+
+func TestValueCopier(t *testing.T) {
+	copies := 0
+	src := map[string]*token{"a": {Value: "a", copies: &copies}}
+	dst := Value(src).(map[string]*token)
+	assert.Equal(t, "a-copy", dst["a"].Value)
+	assert.Equal(t, 1, copies)
+}
+
+type badToken struct {
+	Value string
+}
+
+func (t *badToken) DeepCopy() interface{} {
+	return t.Value
+}
+
+func TestValueCopierTypeMismatch(t *testing.T) {
+	assert.PanicsWithValue(t, "deepcopy: DeepCopy of *deepcopy.badToken returned string, want the same type", func() {
+		Value([]*badToken{{Value: "a"}})
+	})
+}
+
+// payload 可由gob编码的基准测试数据
+type payload struct {
+	ID      int64
+	Tags    []string
+	Attrs   map[string]interface{}
+	Created time.Time
+	Nested  *payload
+}
+
+func benchPayload() *payload {
+	attrs := make(map[string]interface{}, 16)
+	for i := 0; i < 16; i++ {
+		attrs[string(rune('a'+i))] = i
+	}
+	r := &payload{ID: 1, Tags: []string{"x", "y", "z"}, Attrs: attrs, Created: time.Now()}
+	for i := 0; i < 8; i++ {
+		r = &payload{ID: int64(i), Tags: r.Tags, Attrs: attrs, Created: r.Created, Nested: r}
+	}
+	return r
+}
+
+func BenchmarkValue(b *testing.B) {
+	src := benchPayload()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = Value(src)
+	}
+}
+
+func BenchmarkGobRoundTrip(b *testing.B) {
+	gob.Register(0)
+	src := benchPayload()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+			b.Fatal(err)
+		}
+		var dst payload
+		if err := gob.NewDecoder(&buf).Decode(&dst); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
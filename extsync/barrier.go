@@ -0,0 +1,155 @@
+package extsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBrokenBarrier 有等待者放弃等待或屏障被重置, 屏障已损坏
+var ErrBrokenBarrier = errors.New("extsync: broken barrier")
+
+// barrierGen 屏障的一代, 所有等待者到齐或屏障损坏时done被关闭
+type barrierGen struct {
+	done   chan struct{}
+	broken bool
+}
+
+// CyclicBarrier 循环屏障, parties个协程都到达后同时放行, 之后可以重复使用.
+// 有等待者放弃等待时屏障损坏, 所有等待者返回ErrBrokenBarrier, 直到调用Reset.
+type CyclicBarrier struct {
+	parties int
+	action  func()
+
+	mu       sync.Mutex
+	count    int // 当前一代已到达的协程数
+	gen      *barrierGen
+	tripping chan struct{} // 执行action期间不为nil, 结束后关闭, 期间到达的协程等待其关闭后再计入新一代
+}
+
+// NewCyclicBarrier 新建需要parties个协程到达的循环屏障, action不为nil时由最后到达的协程在放行前执行.
+// action在屏障的锁之外执行, action panic时屏障损坏, 其他等待者返回ErrBrokenBarrier.
+func NewCyclicBarrier(parties int, action func()) *CyclicBarrier {
+	if parties <= 0 {
+		panic("extsync: barrier parties must be positive")
+	}
+	return &CyclicBarrier{
+		parties: parties,
+		action:  action,
+		gen:     &barrierGen{done: make(chan struct{})},
+	}
+}
+
+// Await 等待其他协程到达, ctx被取消时放弃等待, 屏障随之损坏.
+// 上一代的action仍在执行时到达的协程先等待新一代开启, 在此期间放弃等待不会损坏屏障.
+func (b *CyclicBarrier) Await(ctx context.Context) error {
+	b.mu.Lock()
+	for b.tripping != nil {
+		tripping := b.tripping
+		b.mu.Unlock()
+		select {
+		case <-tripping:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		b.mu.Lock()
+	}
+	g := b.gen
+	if g.broken {
+		b.mu.Unlock()
+		return ErrBrokenBarrier
+	}
+	b.count++
+	if b.count == b.parties {
+		if b.action != nil {
+			b.tripping = make(chan struct{})
+		}
+		b.mu.Unlock()
+		return b.trip(g)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		b.mu.Lock()
+		select {
+		case <-g.done:
+			// 在放弃的同时屏障已放行或损坏
+		default:
+			b.breakGen(g)
+			b.mu.Unlock()
+			return ctx.Err()
+		}
+		b.mu.Unlock()
+	}
+	if g.broken {
+		return ErrBrokenBarrier
+	}
+	return nil
+}
+
+// trip 最后到达的协程执行action并放行g, 开启下一代.
+func (b *CyclicBarrier) trip(g *barrierGen) error {
+	if b.action != nil {
+		ok := false
+		defer func() {
+			if ok {
+				return
+			}
+			// action panic, 损坏屏障并唤醒等待者, panic继续向上传递
+			b.mu.Lock()
+			if !g.broken {
+				b.breakGen(g)
+			}
+			b.endTrip()
+			b.mu.Unlock()
+		}()
+		b.action()
+		ok = true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.endTrip()
+	if g.broken {
+		// 执行action期间有等待者放弃等待或屏障被重置
+		return ErrBrokenBarrier
+	}
+	b.count = 0
+	b.gen = &barrierGen{done: make(chan struct{})}
+	close(g.done)
+	return nil
+}
+
+// Reset 重置屏障, 正在等待的协程返回ErrBrokenBarrier.
+func (b *CyclicBarrier) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.gen.broken {
+		b.breakGen(b.gen)
+	}
+	b.count = 0
+	b.gen = &barrierGen{done: make(chan struct{})}
+}
+
+// Waiting 返回当前正在等待的协程数.
+func (b *CyclicBarrier) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// endTrip 放行执行action期间到达的协程, 调用方需持有b.mu.
+func (b *CyclicBarrier) endTrip() {
+	if b.tripping != nil {
+		close(b.tripping)
+		b.tripping = nil
+	}
+}
+
+// breakGen 损坏当前一代, 调用方需持有b.mu.
+func (b *CyclicBarrier) breakGen(g *barrierGen) {
+	g.broken = true
+	close(g.done)
+	b.count = 0
+}
@@ -0,0 +1,129 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCyclicBarrier(t *testing.T) {
+	const parties, rounds = 4, 50
+	var trips int32
+	b := NewCyclicBarrier(parties, func() { atomic.AddInt32(&trips, 1) })
+	var (
+		wg      sync.WaitGroup
+		arrived [rounds]int32
+	)
+	for g := 0; g < parties; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				atomic.AddInt32(&arrived[r], 1)
+				assert.Empty(t, b.Await(context.Background()))
+				// 放行时所有协程都已到达本轮
+				assert.Equal(t, int32(parties), atomic.LoadInt32(&arrived[r]))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(rounds), atomic.LoadInt32(&trips))
+	assert.Equal(t, 0, b.Waiting())
+}
+
+func TestCyclicBarrierArriveDuringAction(t *testing.T) {
+	inAction, release := make(chan struct{}), make(chan struct{})
+	trips := 0
+	b := NewCyclicBarrier(2, func() {
+		if trips++; trips == 1 {
+			close(inAction)
+			<-release
+		}
+	})
+	first := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			first <- b.Await(context.Background())
+		}()
+	}
+	<-inAction
+
+	// 执行action期间到达的协程不计入上一代, 也不会随上一代被放行
+	late := make(chan error, 1)
+	go func() {
+		late <- b.Await(context.Background())
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, b.Await(ctx))
+	assert.Equal(t, 2, b.Waiting())
+	select {
+	case err := <-late:
+		t.Fatalf("arrived during the action but released with error %v", err)
+	default:
+	}
+
+	close(release)
+	assert.Empty(t, <-first)
+	assert.Empty(t, <-first)
+	assert.Eventually(t, func() bool { return b.Waiting() == 1 }, time.Second, time.Millisecond)
+	assert.Empty(t, b.Await(context.Background()))
+	assert.Empty(t, <-late)
+	assert.Equal(t, 2, trips)
+	assert.Equal(t, 0, b.Waiting())
+}
+
+func TestCyclicBarrierBroken(t *testing.T) {
+	b := NewCyclicBarrier(3, nil)
+	waiter := make(chan error)
+	go func() { waiter <- b.Await(context.Background()) }()
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, b.Await(ctx))
+	assert.Equal(t, ErrBrokenBarrier, <-waiter)
+	assert.Equal(t, ErrBrokenBarrier, b.Await(context.Background()))
+
+	b.Reset()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Empty(t, b.Await(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	go func() { waiter <- b.Await(context.Background()) }()
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Reset()
+	assert.Equal(t, ErrBrokenBarrier, <-waiter)
+}
+
+func TestCyclicBarrierActionPanic(t *testing.T) {
+	var b *CyclicBarrier
+	b = NewCyclicBarrier(2, func() {
+		// action在锁外执行, 可以访问屏障
+		_ = b.Waiting()
+		panic("boom")
+	})
+	waiter := make(chan error)
+	go func() { waiter <- b.Await(context.Background()) }()
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.PanicsWithValue(t, "boom", func() { b.Await(context.Background()) }) // nolint
+	assert.Equal(t, ErrBrokenBarrier, <-waiter)
+	assert.Equal(t, ErrBrokenBarrier, b.Await(context.Background()))
+	assert.Equal(t, 0, b.Waiting())
+}
@@ -0,0 +1,88 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+)
+
+// Group 一组协同执行的任务, 类似errgroup, 零值可用.
+// 可以限制同时执行的任务数, 任一任务失败时取消NewGroupWithContext返回的ctx, Wait返回第一个错误.
+// 任务中的panic会被转换为*PanicError.
+type Group struct {
+	cancel func()
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroupWithContext 新建Group, 返回的ctx在任一任务失败或Wait返回时被取消.
+func NewGroupWithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit 限制同时执行的任务数, n不大于0时不限制; 不能在有任务执行时调用.
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic("extsync: modify limit while tasks are running")
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go 在新的协程中执行f, 同时执行的任务数达到上限时阻塞.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.run(f)
+}
+
+// TryGo 同时执行的任务数未达到上限时在新的协程中执行f并返回true, 否则返回false.
+func (g *Group) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.run(f)
+	return true
+}
+
+func (g *Group) run(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := call(f); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel()
+				}
+			})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// Wait 等待所有任务结束, 返回第一个失败任务的错误.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	return g.err
+}
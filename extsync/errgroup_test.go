@@ -0,0 +1,57 @@
+package extsync
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupLimit(t *testing.T) {
+	var g Group
+	g.SetLimit(3)
+	var running, peak int32
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	assert.Empty(t, g.Wait())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&peak))
+
+	block := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		assert.True(t, g.TryGo(func() error { <-block; return nil }))
+	}
+	assert.False(t, g.TryGo(func() error { return nil }))
+	close(block)
+	assert.Empty(t, g.Wait())
+}
+
+func TestNewGroupWithContext(t *testing.T) {
+	g, ctx := NewGroupWithContext(context.Background())
+	errFirst := errors.New("first")
+	g.Go(func() error { return errFirst })
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	g.Go(func() error { panic("boom") })
+	err := g.Wait()
+	// 第一个错误可能来自失败的任务, 也可能来自panic
+	var pe *PanicError
+	assert.True(t, err == errFirst || errors.As(err, &pe))
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
@@ -1,8 +1,10 @@
 package extsync
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -13,30 +15,72 @@ const (
 	mutexWaiterShift = iota
 )
 
-// Mutex 高级互斥锁
+// Mutex 高级互斥锁, 零值可用.
+// Lock、LockContext和TryLockFor在同一个FIFO队列中按到达顺序排队, 锁被释放时直接交给队首的等待者,
+// 通过LockContext或TryLockFor等待的协程可以中途放弃, 放弃后立即从队列中移除.
+// 轮到某个请求时才加锁内嵌的sync.Mutex, 直接调用内嵌sync.Mutex的方法会绕过队列, 不应与本类型的方法混用.
 type Mutex struct {
 	sync.Mutex
+	sema fifoSema
 }
 
-// TryLock 尝试获取锁(非阻塞).
-func (m *Mutex) TryLock() bool {
-	if atomic.CompareAndSwapInt32((*int32)(unsafe.Pointer(&m.Mutex)), 0, mutexLocked) {
-		// fast path
-		return true
+// Lock 获取锁.
+func (m *Mutex) Lock() {
+	m.LockContext(context.Background()) // nolint
+}
+
+// Unlock 释放锁.
+func (m *Mutex) Unlock() {
+	if p := loadProfiler(); p != nil {
+		p.release(uintptr(unsafe.Pointer(m)))
 	}
-	old := atomic.LoadInt32((*int32)(unsafe.Pointer(&m.Mutex)))
-	if old&(mutexLocked|mutexWoken|mutexStarving) != 0 {
+	m.Mutex.Unlock()
+	m.sema.release(1, 1)
+}
+
+// TryLock 尝试获取锁(非阻塞), 队列中有等待者时不会插队.
+func (m *Mutex) TryLock() bool {
+	if !m.sema.tryAcquire(1, 1) {
 		return false
 	}
-	new := old | mutexLocked
-	return atomic.CompareAndSwapInt32((*int32)(unsafe.Pointer(&m.Mutex)), old, new)
+	if p := loadProfiler(); p != nil {
+		p.tryAcquired(uintptr(unsafe.Pointer(m)), false)
+	}
+	m.Mutex.Lock()
+	return true
 }
 
-// Count 统计当前等待获取锁的协程数.
+// LockContext 获取锁, ctx被取消时放弃等待并返回ctx.Err().
+func (m *Mutex) LockContext(ctx context.Context) error {
+	var err error
+	if p := loadProfiler(); p != nil {
+		err = p.acquire(uintptr(unsafe.Pointer(m)), false, func() error {
+			return m.sema.acquire(ctx, 1, 1)
+		})
+	} else {
+		err = m.sema.acquire(ctx, 1, 1)
+	}
+	if err != nil {
+		return err
+	}
+	m.Mutex.Lock()
+	return nil
+}
+
+// TryLockFor 尝试在d时间内获取锁, d不大于0时等价于TryLock.
+func (m *Mutex) TryLockFor(d time.Duration) bool {
+	if d <= 0 {
+		return m.TryLock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return m.LockContext(ctx) == nil
+}
+
+// Count 统计当前持有和等待获取锁的协程数.
 func (m *Mutex) Count() int {
-	v := atomic.LoadInt32((*int32)(unsafe.Pointer(&m.Mutex)))
-	v = v>>mutexWaiterShift + (v & mutexLocked)
-	return int(v)
+	held, waiting := m.sema.state()
+	return int(held) + waiting
 }
 
 // IsLocked 判断锁当前是否处于被持有状态.
@@ -0,0 +1,244 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitCount 等待m上持有和等待锁的协程数达到n.
+func waitCount(m *Mutex, n int) {
+	for m.Count() != n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMutexLockContext(t *testing.T) {
+	var m Mutex
+	assert.True(t, m.TryLock())
+	assert.False(t, m.TryLock())
+	assert.True(t, m.IsLocked())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, m.LockContext(ctx))
+	assert.False(t, m.TryLockFor(10*time.Millisecond))
+	// 放弃等待的协程已从等待队列中移除
+	assert.Equal(t, 1, m.Count())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Unlock()
+	}()
+	assert.True(t, m.TryLockFor(time.Second))
+	m.Unlock()
+	assert.False(t, m.IsLocked())
+	assert.Equal(t, 0, m.Count())
+}
+
+func TestMutexCancelUnderContention(t *testing.T) {
+	var m Mutex
+	m.Lock()
+	const waiters = 32
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+		canceled int
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := context.Background()
+			if i%2 == 0 {
+				c = ctx
+			}
+			if err := m.LockContext(c); err != nil {
+				mu.Lock()
+				canceled++
+				mu.Unlock()
+				return
+			}
+			mu.Lock()
+			acquired++
+			mu.Unlock()
+			m.Unlock()
+		}(i)
+	}
+	waitCount(&m, waiters+1)
+	cancel()
+	waitCount(&m, waiters/2+1)
+	m.Unlock()
+	wg.Wait()
+	assert.Equal(t, waiters/2, acquired)
+	assert.Equal(t, waiters/2, canceled)
+	assert.Equal(t, 0, m.Count())
+	assert.True(t, m.TryLock())
+}
+
+func TestMutexFIFO(t *testing.T) {
+	var m Mutex
+	m.Lock()
+	const waiters = 8
+	order := make(chan int, waiters)
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			switch i % 3 {
+			case 0:
+				m.Lock()
+			case 1:
+				err = m.LockContext(context.Background())
+			case 2:
+				if !m.TryLockFor(time.Minute) {
+					err = context.DeadlineExceeded
+				}
+			}
+			assert.Empty(t, err)
+			order <- i
+			m.Unlock()
+		}(i)
+		// 逐个进入队列, 保证到达顺序
+		waitCount(&m, i+2)
+	}
+	m.Unlock()
+	wg.Wait()
+	close(order)
+	i := 0
+	for got := range order {
+		assert.Equal(t, i, got)
+		i++
+	}
+	assert.Equal(t, waiters, i)
+}
+
+// spin 循环加锁并短暂持有, 直到stop被关闭.
+func spin(lock, unlock func(), stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			lock()
+			time.Sleep(100 * time.Microsecond)
+			unlock()
+		}
+	}()
+	return done
+}
+
+// lockAllContext 并发调用n次lockContext, 返回放弃等待的次数.
+func lockAllContext(n int, lockContext func(ctx context.Context) error, unlock func()) int {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			if err := lockContext(ctx); err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return
+			}
+			unlock()
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+func TestMutexLockContextProgress(t *testing.T) {
+	// 持续使用Lock的协程不会饿死带ctx的等待者
+	var m Mutex
+	stop := make(chan struct{})
+	done := spin(m.Lock, m.Unlock, stop)
+	assert.Equal(t, 0, lockAllContext(20, m.LockContext, m.Unlock))
+	close(stop)
+	<-done
+
+	var rw RWMutex
+	stop = make(chan struct{})
+	done = spin(rw.Lock, rw.Unlock, stop)
+	assert.Equal(t, 0, lockAllContext(20, rw.RLockContext, rw.RUnlock))
+	assert.Equal(t, 0, lockAllContext(20, rw.LockContext, rw.Unlock))
+	close(stop)
+	<-done
+}
+
+func TestRWMutexLockContext(t *testing.T) {
+	var rw RWMutex
+	rw.RLock()
+
+	// 等待中的写者阻塞其后到达的读者
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- rw.LockContext(ctx)
+	}()
+	for rw.TryRLock() {
+		rw.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	assert.False(t, rw.TryRLockFor(10*time.Millisecond))
+
+	// 写者放弃等待后, 读者可以继续获取读锁
+	reader := make(chan error)
+	go func() {
+		reader <- rw.RLockContext(context.Background())
+	}()
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Empty(t, <-reader)
+	assert.Equal(t, int32(2), rw.RCount())
+	rw.RUnlock()
+	rw.RUnlock()
+
+	assert.True(t, rw.TryLockFor(time.Second))
+	assert.True(t, rw.WExist())
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel2()
+	assert.Equal(t, context.DeadlineExceeded, rw.RLockContext(ctx2))
+	assert.Equal(t, int32(0), rw.RCount())
+	rw.Unlock()
+}
+
+func TestReentrantMutexLockContext(t *testing.T) {
+	var m ReentrantMutex
+	assert.Empty(t, m.LockContext(context.Background()))
+	assert.True(t, m.TryLockFor(0))
+
+	done := make(chan bool)
+	go func() {
+		done <- m.TryLockFor(10 * time.Millisecond)
+	}()
+	assert.False(t, <-done)
+
+	m.Unlock()
+	m.Unlock()
+	go func() {
+		ok := m.TryLockFor(time.Second)
+		if ok {
+			m.Unlock()
+		}
+		done <- ok
+	}()
+	assert.True(t, <-done)
+}
@@ -1,14 +1,66 @@
 package extsync
 
 import (
+	"fmt"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
-// Once 一个功能更加强大的Once
+// PanicError 初始化函数发生panic时返回的错误
+type PanicError struct {
+	Value interface{} // recover得到的值
+	Stack []byte      // 发生panic时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in once function: %v\n%s", e.Value, e.Stack)
+}
+
+// Backoff 初始化失败后的指数退避策略, 零值代表不退避.
+type Backoff struct {
+	Min    time.Duration // 第一次失败后的退避时长, 不大于0时不退避
+	Max    time.Duration // 退避时长上限, 不大于0时不设上限
+	Factor float64       // 每次失败后退避时长的增长倍数, 不大于1时取2
+}
+
+// delay 返回第failures次失败后的退避时长.
+func (b *Backoff) delay(failures int) time.Duration {
+	if b.Min <= 0 {
+		return 0
+	}
+	factor := b.Factor
+	if factor <= 1 {
+		factor = 2
+	}
+	d := float64(b.Min)
+	for i := 1; i < failures; i++ {
+		d *= factor
+		if b.Max > 0 && d >= float64(b.Max) {
+			return b.Max
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(d)
+}
+
+// Once 一个功能更加强大的Once, 零值可用.
+// 初始化失败时下一次调用会重试, 初始化成功后可以通过Reset重新初始化, f中的panic会被转换为*PanicError返回.
 type Once struct {
-	done uint32
-	m    sync.Mutex
+	// Backoff 初始化失败后的退避策略, 退避期间的调用直接返回上一次的错误
+	Backoff Backoff
+	// ShareError 为true时, 等待正在进行的初始化的调用直接返回其错误, 而不是再次重试
+	ShareError bool
+
+	done     uint32
+	attempts uint64 // 已执行初始化的次数
+	m        sync.Mutex
+	err      error // 上一次初始化的错误
+	failures int   // 连续失败的次数
+	retryAt  time.Time
 }
 
 // Do 传入的函数f带返回值error, 如果初始化失败, 需要返回error
@@ -21,14 +73,81 @@ func (o *Once) Do(f func() error) error {
 }
 
 func (o *Once) doSlow(f func() error) error {
+	attempts := atomic.LoadUint64(&o.attempts)
 	o.m.Lock()
 	defer o.m.Unlock()
-	var err error
-	if o.done == 0 { // double-checking, 还未初始化
-		if err = f(); err == nil {
-			// 初始化成功才将标记位设置为已初始化
-			atomic.StoreUint32(&o.done, 1)
+	if o.done == 1 { // double-checking, 已被其他协程初始化
+		return nil
+	}
+	if o.err != nil {
+		if o.ShareError && o.attempts != attempts {
+			// 等待期间其他协程的初始化失败了
+			return o.err
+		}
+		if time.Now().Before(o.retryAt) {
+			return o.err
 		}
 	}
-	return err
+
+	err := call(f)
+	atomic.AddUint64(&o.attempts, 1)
+	if err != nil {
+		o.err = err
+		o.failures++
+		o.retryAt = time.Now().Add(o.Backoff.delay(o.failures))
+		return err
+	}
+	// 初始化成功才将标记位设置为已初始化
+	o.err, o.failures = nil, 0
+	atomic.StoreUint32(&o.done, 1)
+	return nil
+}
+
+// call 执行f, 并将f中的panic转换为*PanicError.
+func call(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
+// Done 判断是否已初始化成功.
+func (o *Once) Done() bool {
+	return atomic.LoadUint32(&o.done) == 1
+}
+
+// Reset 重置为未初始化状态, 下一次调用Do时会重新初始化, 适用于连接等可能失效的资源.
+func (o *Once) Reset() {
+	o.m.Lock()
+	defer o.m.Unlock()
+	o.err, o.failures, o.retryAt = nil, 0, time.Time{}
+	atomic.StoreUint32(&o.done, 0)
+}
+
+// OnceValue 缓存初始化结果的Once, 零值可用, 重试、退避和重置的行为与Once相同.
+type OnceValue struct {
+	Once
+	v atomic.Value // *onceResult
+}
+
+type onceResult struct {
+	v interface{}
+}
+
+// Do 初始化成功时缓存并返回f的结果, 之后的调用直接返回缓存的结果.
+func (o *OnceValue) Do(f func() (interface{}, error)) (interface{}, error) {
+	err := o.Once.Do(func() error {
+		v, err := f()
+		if err != nil {
+			return err
+		}
+		o.v.Store(&onceResult{v: v})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.v.Load().(*onceResult).v, nil
 }
@@ -0,0 +1,130 @@
+package extsync
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errInit = errors.New("init failed")
+
+func TestOnceRetryAndReset(t *testing.T) {
+	var o Once
+	calls := 0
+	fail := func() error { calls++; return errInit }
+	ok := func() error { calls++; return nil }
+
+	assert.Equal(t, errInit, o.Do(fail))
+	assert.Equal(t, errInit, o.Do(fail))
+	assert.False(t, o.Done())
+	assert.Empty(t, o.Do(ok))
+	assert.Empty(t, o.Do(fail))
+	assert.True(t, o.Done())
+	assert.Equal(t, 3, calls)
+
+	o.Reset()
+	assert.False(t, o.Done())
+	assert.Equal(t, errInit, o.Do(fail))
+	assert.Equal(t, 4, calls)
+}
+
+func TestOncePanic(t *testing.T) {
+	var o Once
+	err := o.Do(func() error { panic("boom") })
+	var pe *PanicError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "boom", pe.Value)
+	assert.NotEmpty(t, pe.Stack)
+	// panic之后仍然可以重试
+	assert.Empty(t, o.Do(func() error { return nil }))
+}
+
+func TestOnceBackoff(t *testing.T) {
+	o := Once{Backoff: Backoff{Min: 20 * time.Millisecond, Max: 30 * time.Millisecond}}
+	calls := 0
+	fail := func() error { calls++; return errInit }
+	assert.Equal(t, errInit, o.Do(fail))
+	// 退避期间直接返回上一次的错误
+	assert.Equal(t, errInit, o.Do(fail))
+	assert.Equal(t, 1, calls)
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, errInit, o.Do(fail))
+	assert.Equal(t, 2, calls)
+
+	b := Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond, Factor: 3}
+	assert.Equal(t, time.Millisecond, b.delay(1))
+	assert.Equal(t, 3*time.Millisecond, b.delay(2))
+	assert.Equal(t, 9*time.Millisecond, b.delay(3))
+	assert.Equal(t, 10*time.Millisecond, b.delay(4))
+	assert.Equal(t, time.Duration(0), (&Backoff{}).delay(3))
+}
+
+func TestOnceShareError(t *testing.T) {
+	o := Once{ShareError: true}
+	var calls int32
+	release := make(chan struct{})
+	f := func() error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return errInit
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- o.Do(f)
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Equal(t, errInit, err)
+	}
+	// 只有最先进入的调用执行了初始化, 等待中的调用直接得到了它的错误
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestOnceValue(t *testing.T) {
+	var o OnceValue
+	calls := 0
+	v, err := o.Do(func() (interface{}, error) { calls++; return nil, errInit })
+	assert.Nil(t, v)
+	assert.Equal(t, errInit, err)
+	v, err = o.Do(func() (interface{}, error) { calls++; return "conn-1", nil })
+	assert.Empty(t, err)
+	assert.Equal(t, "conn-1", v)
+	v, _ = o.Do(func() (interface{}, error) { calls++; return "conn-2", nil })
+	assert.Equal(t, "conn-1", v)
+	assert.Equal(t, 2, calls)
+
+	o.Reset()
+	v, _ = o.Do(func() (interface{}, error) { calls++; return "conn-2", nil })
+	assert.Equal(t, "conn-2", v)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%4 == 0 {
+				o.Reset()
+			}
+			v, err := o.Do(func() (interface{}, error) { return "conn", nil })
+			assert.Empty(t, err)
+			assert.NotNil(t, v)
+		}(i)
+	}
+	wg.Wait()
+}
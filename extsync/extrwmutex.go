@@ -1,48 +1,161 @@
 package extsync
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 const rwmutexMaxReaders = 1 << 30
 
-// RWMutex 高级读写锁
+// RWMutex 高级读写锁, 零值可用.
+// 加锁请求在FIFO队列中按到达顺序排队, 等待写锁的协程会阻塞其后到达的读者, 因此写者不会被源源不断的读者饿死;
+// 可以通过LockContext、RLockContext等方法中途放弃等待, 放弃后立即从队列中移除.
+// 轮到某个请求时才加锁内嵌的sync.RWMutex, 直接调用内嵌sync.RWMutex的方法会绕过队列, 不应与本类型的方法混用.
 type RWMutex struct {
 	sync.RWMutex
+	sema fifoSema // 读者占用1, 写者占用rwmutexMaxReaders
+
+	readers int32 // 持有或等待读锁的协程数
+	writer  int32 // 是否有协程持有写锁
 }
 
-// TryRWLock 尝试获取读写锁(非阻塞), read == true代表想获取读锁, 否则代表想获取写锁.
-func (rw *RWMutex) TryRWLock(read bool) bool {
-	readerCntPtr := (*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rw)) + unsafe.Sizeof(sync.Mutex{}) + 2*unsafe.Sizeof(uint32(0))))
-	readerCnt := atomic.LoadInt32(readerCntPtr)
-	if readerCnt < 0 {
-		// 被其他写锁持有
+// Lock 获取写锁.
+func (rw *RWMutex) Lock() {
+	rw.LockContext(context.Background()) // nolint
+}
+
+// Unlock 释放写锁.
+func (rw *RWMutex) Unlock() {
+	if p := loadProfiler(); p != nil {
+		p.release(uintptr(unsafe.Pointer(rw)))
+	}
+	atomic.StoreInt32(&rw.writer, 0)
+	rw.RWMutex.Unlock()
+	rw.sema.release(rwmutexMaxReaders, rwmutexMaxReaders)
+}
+
+// RLock 获取读锁.
+func (rw *RWMutex) RLock() {
+	rw.RLockContext(context.Background()) // nolint
+}
+
+// RUnlock 释放读锁.
+func (rw *RWMutex) RUnlock() {
+	if p := loadProfiler(); p != nil {
+		p.release(uintptr(unsafe.Pointer(rw)))
+	}
+	rw.RWMutex.RUnlock()
+	atomic.AddInt32(&rw.readers, -1)
+	rw.sema.release(1, rwmutexMaxReaders)
+}
+
+// RLocker 返回以读锁实现sync.Locker接口的对象.
+func (rw *RWMutex) RLocker() sync.Locker {
+	return (*rlocker)(rw)
+}
+
+// TryLock 尝试获取写锁(非阻塞), 队列中有等待者时不会插队.
+func (rw *RWMutex) TryLock() bool {
+	if !rw.sema.tryAcquire(rwmutexMaxReaders, rwmutexMaxReaders) {
 		return false
 	}
+	if p := loadProfiler(); p != nil {
+		p.tryAcquired(uintptr(unsafe.Pointer(rw)), false)
+	}
+	rw.RWMutex.Lock()
+	atomic.StoreInt32(&rw.writer, 1)
+	return true
+}
 
-	if read && atomic.CompareAndSwapInt32(readerCntPtr, readerCnt, readerCnt+1) {
-		return true
-	} else if !read && atomic.CompareAndSwapInt32(readerCntPtr, readerCnt, -rwmutexMaxReaders) {
-		rw.Lock()
-		return true
+// TryRLock 尝试获取读锁(非阻塞), 队列中有等待者时不会插队.
+func (rw *RWMutex) TryRLock() bool {
+	if !rw.sema.tryAcquire(1, rwmutexMaxReaders) {
+		return false
+	}
+	if p := loadProfiler(); p != nil {
+		p.tryAcquired(uintptr(unsafe.Pointer(rw)), true)
 	}
+	atomic.AddInt32(&rw.readers, 1)
+	rw.RWMutex.RLock()
+	return true
+}
 
-	return false
+// LockContext 获取写锁, ctx被取消时放弃等待并返回ctx.Err().
+func (rw *RWMutex) LockContext(ctx context.Context) error {
+	if err := rw.acquire(false, func() error {
+		return rw.sema.acquire(ctx, rwmutexMaxReaders, rwmutexMaxReaders)
+	}); err != nil {
+		return err
+	}
+	rw.RWMutex.Lock()
+	atomic.StoreInt32(&rw.writer, 1)
+	return nil
 }
 
-// RCount 统计当前等待获取读锁的协程数.
-func (rw *RWMutex) RCount() int32 {
-	readerCntPtr := (*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rw)) + unsafe.Sizeof(sync.Mutex{}) + 2*unsafe.Sizeof(uint32(0))))
-	readerCnt := atomic.LoadInt32(readerCntPtr)
-	if readerCnt < 0 {
-		return atomic.LoadInt32((*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rw)) + unsafe.Sizeof(sync.Mutex{}) + 2*unsafe.Sizeof(uint32(0)) + unsafe.Sizeof(int32(0)))))
+// RLockContext 获取读锁, ctx被取消时放弃等待并返回ctx.Err().
+func (rw *RWMutex) RLockContext(ctx context.Context) error {
+	atomic.AddInt32(&rw.readers, 1)
+	if err := rw.acquire(true, func() error {
+		return rw.sema.acquire(ctx, 1, rwmutexMaxReaders)
+	}); err != nil {
+		atomic.AddInt32(&rw.readers, -1)
+		return err
+	}
+	rw.RWMutex.RLock()
+	return nil
+}
+
+func (rw *RWMutex) acquire(shared bool, fn func() error) error {
+	if p := loadProfiler(); p != nil {
+		return p.acquire(uintptr(unsafe.Pointer(rw)), shared, fn)
+	}
+	return fn()
+}
+
+// TryLockFor 尝试在d时间内获取写锁, d不大于0时等价于TryLock.
+func (rw *RWMutex) TryLockFor(d time.Duration) bool {
+	if d <= 0 {
+		return rw.TryLock()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.LockContext(ctx) == nil
+}
+
+// TryRLockFor 尝试在d时间内获取读锁, d不大于0时等价于TryRLock.
+func (rw *RWMutex) TryRLockFor(d time.Duration) bool {
+	if d <= 0 {
+		return rw.TryRLock()
 	}
-	return readerCnt
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return rw.RLockContext(ctx) == nil
+}
+
+// TryRWLock 尝试获取读写锁(非阻塞), read == true代表想获取读锁, 否则代表想获取写锁.
+//
+// Deprecated: 使用TryRLock或TryLock.
+func (rw *RWMutex) TryRWLock(read bool) bool {
+	if read {
+		return rw.TryRLock()
+	}
+	return rw.TryLock()
+}
+
+// RCount 统计当前持有或等待获取读锁的协程数, 与标准库readerCount的含义一致, 在队列中等待的读者同样被计入.
+func (rw *RWMutex) RCount() int32 {
+	return atomic.LoadInt32(&rw.readers)
 }
 
 // WExist 查看当前是否有持有写锁的协程.
 func (rw *RWMutex) WExist() bool {
-	return atomic.LoadInt32((*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(&rw))+unsafe.Sizeof(sync.Mutex{})+unsafe.Sizeof(uint32(0))))) < 0
+	return atomic.LoadInt32(&rw.writer) == 1
 }
+
+type rlocker RWMutex
+
+func (r *rlocker) Lock()   { (*RWMutex)(r).RLock() }
+func (r *rlocker) Unlock() { (*RWMutex)(r).RUnlock() }
@@ -0,0 +1,170 @@
+package extsync
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tryRWLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+	TryLock() bool
+	TryRLock() bool
+}
+
+func testTryRWLock(t *testing.T, rw tryRWLocker) {
+	assert.True(t, rw.TryRLock())
+	assert.True(t, rw.TryRLock())
+	assert.False(t, rw.TryLock())
+	rw.RUnlock()
+	rw.RUnlock()
+
+	assert.True(t, rw.TryLock())
+	assert.False(t, rw.TryLock())
+	assert.False(t, rw.TryRLock())
+	rw.Unlock()
+
+	rw.Lock()
+	assert.False(t, rw.TryRLock())
+	rw.Unlock()
+	rw.RLock()
+	assert.True(t, rw.TryRLock())
+	rw.RUnlock()
+	rw.RUnlock()
+}
+
+// stressRWLock 混合使用阻塞和非阻塞的加锁方式, 校验读写互斥.
+func stressRWLock(t *testing.T, rw tryRWLocker) {
+	var (
+		wg      sync.WaitGroup
+		readers int32
+		writers int32
+		shared  int
+	)
+	check := func() {
+		r, w := atomic.LoadInt32(&readers), atomic.LoadInt32(&writers)
+		if w > 1 || (w == 1 && r > 0) {
+			t.Errorf("readers (%d) and writers (%d) hold the lock at the same time", r, w)
+		}
+	}
+	write := func() {
+		atomic.AddInt32(&writers, 1)
+		check()
+		shared++
+		atomic.AddInt32(&writers, -1)
+	}
+	read := func() {
+		atomic.AddInt32(&readers, 1)
+		check()
+		_ = shared
+		atomic.AddInt32(&readers, -1)
+	}
+
+	const goroutines, iterations = 16, 500
+	locked := int32(0)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				switch (g + i) % 4 {
+				case 0:
+					rw.Lock()
+					write()
+					rw.Unlock()
+					atomic.AddInt32(&locked, 1)
+				case 1:
+					if rw.TryLock() {
+						write()
+						rw.Unlock()
+						atomic.AddInt32(&locked, 1)
+					}
+				case 2:
+					rw.RLock()
+					read()
+					rw.RUnlock()
+				case 3:
+					if rw.TryRLock() {
+						read()
+						rw.RUnlock()
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, int(locked), shared)
+	assert.True(t, rw.TryLock())
+	rw.Unlock()
+}
+
+func TestRWMutexTryLock(t *testing.T) {
+	var rw RWMutex
+	testTryRWLock(t, &rw)
+
+	assert.True(t, rw.TryRWLock(true))
+	assert.Equal(t, int32(1), rw.RCount())
+	assert.False(t, rw.WExist())
+	rw.RUnlock()
+	assert.True(t, rw.TryRWLock(false))
+	assert.True(t, rw.WExist())
+	rw.Unlock()
+	assert.Equal(t, int32(0), rw.RCount())
+
+	l := rw.RLocker()
+	l.Lock()
+	assert.False(t, rw.TryLock())
+	l.Unlock()
+}
+
+func TestRWMutexStress(t *testing.T) {
+	stressRWLock(t, &RWMutex{})
+}
+
+func TestRWMutexRCount(t *testing.T) {
+	var rw RWMutex
+	rw.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rw.RLock()
+		}()
+	}
+	// 写锁被持有时, 等待中的读者同样被计入
+	assert.Eventually(t, func() bool { return rw.RCount() == 3 }, time.Second, time.Millisecond)
+	assert.True(t, rw.WExist())
+	rw.Unlock()
+	wg.Wait()
+	assert.Equal(t, int32(3), rw.RCount())
+	assert.False(t, rw.WExist())
+
+	for i := 0; i < 3; i++ {
+		rw.RUnlock()
+	}
+	assert.Equal(t, int32(0), rw.RCount())
+}
+
+func benchmarkRLock(b *testing.B, rw sync.Locker) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rw.Lock()
+			rw.Unlock()
+		}
+	})
+}
+
+func BenchmarkRWMutexRLock(b *testing.B) {
+	benchmarkRLock(b, (&RWMutex{}).RLocker())
+}
+
+func BenchmarkStdRWMutexRLock(b *testing.B) {
+	benchmarkRLock(b, (&sync.RWMutex{}).RLocker())
+}
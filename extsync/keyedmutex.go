@@ -0,0 +1,147 @@
+package extsync
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// keyedEntry 一个键对应的锁, refs为持有和等待该锁的协程数
+type keyedEntry struct {
+	m    Mutex
+	refs int
+}
+
+// KeyedMutex 按键加锁的互斥锁, 零值可用.
+// 每个键的锁在首次使用时创建, 在没有协程持有或等待时释放, 因此适用于键的数量无界的场景.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedEntry
+}
+
+// Lock 获取key对应的锁.
+func (km *KeyedMutex) Lock(key string) {
+	km.LockContext(context.Background(), key) // nolint
+}
+
+// Unlock 释放key对应的锁.
+func (km *KeyedMutex) Unlock(key string) {
+	km.mu.Lock()
+	e, ok := km.locks[key]
+	km.mu.Unlock()
+	// 键存在时也可能只有等待者而没有持有者, 需在调用Mutex.Unlock之前检查, 否则会触发无法recover的fatal error
+	if !ok || !e.m.IsLocked() {
+		panic("extsync: unlock of unlocked key " + key)
+	}
+	e.m.Unlock()
+	km.unref(key, e)
+}
+
+// TryLock 尝试获取key对应的锁(非阻塞).
+func (km *KeyedMutex) TryLock(key string) bool {
+	e := km.ref(key)
+	if e.m.TryLock() {
+		return true
+	}
+	km.unref(key, e)
+	return false
+}
+
+// LockContext 获取key对应的锁, ctx被取消时放弃等待并返回ctx.Err().
+func (km *KeyedMutex) LockContext(ctx context.Context, key string) error {
+	e := km.ref(key)
+	if err := e.m.LockContext(ctx); err != nil {
+		km.unref(key, e)
+		return err
+	}
+	return nil
+}
+
+// TryLockFor 尝试在d时间内获取key对应的锁, d不大于0时等价于TryLock.
+func (km *KeyedMutex) TryLockFor(key string, d time.Duration) bool {
+	if d <= 0 {
+		return km.TryLock(key)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return km.LockContext(ctx, key) == nil
+}
+
+// Len 返回当前被持有或等待的键的数量.
+func (km *KeyedMutex) Len() int {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	return len(km.locks)
+}
+
+func (km *KeyedMutex) ref(key string) *keyedEntry {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedEntry)
+	}
+	e, ok := km.locks[key]
+	if !ok {
+		e = &keyedEntry{}
+		km.locks[key] = e
+	}
+	e.refs++
+	return e
+}
+
+func (km *KeyedMutex) unref(key string, e *keyedEntry) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	if e.refs--; e.refs == 0 {
+		delete(km.locks, key)
+	}
+}
+
+// StripedMutex 固定数量的分段锁, 键按FNV-1a哈希映射到各个分段, 与batchprocess.FNV1av32的映射方式一致.
+// 不同的键可能映射到同一个分段, 因此同一个协程不能同时持有多个键的锁, 否则可能死锁.
+type StripedMutex struct {
+	stripes []Mutex
+}
+
+// NewStripedMutex 新建包含n个分段的StripedMutex, n不大于0时取1.
+func NewStripedMutex(n int) *StripedMutex {
+	if n <= 0 {
+		n = 1
+	}
+	return &StripedMutex{
+		stripes: make([]Mutex, n),
+	}
+}
+
+// Stripe 返回key映射到的分段序号.
+func (sm *StripedMutex) Stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key)) // nolint
+	return int(h.Sum32() % uint32(len(sm.stripes)))
+}
+
+// Lock 获取key所在分段的锁.
+func (sm *StripedMutex) Lock(key string) {
+	sm.stripes[sm.Stripe(key)].Lock()
+}
+
+// Unlock 释放key所在分段的锁.
+func (sm *StripedMutex) Unlock(key string) {
+	sm.stripes[sm.Stripe(key)].Unlock()
+}
+
+// TryLock 尝试获取key所在分段的锁(非阻塞).
+func (sm *StripedMutex) TryLock(key string) bool {
+	return sm.stripes[sm.Stripe(key)].TryLock()
+}
+
+// LockContext 获取key所在分段的锁, ctx被取消时放弃等待并返回ctx.Err().
+func (sm *StripedMutex) LockContext(ctx context.Context, key string) error {
+	return sm.stripes[sm.Stripe(key)].LockContext(ctx)
+}
+
+// TryLockFor 尝试在d时间内获取key所在分段的锁, d不大于0时等价于TryLock.
+func (sm *StripedMutex) TryLockFor(key string, d time.Duration) bool {
+	return sm.stripes[sm.Stripe(key)].TryLockFor(d)
+}
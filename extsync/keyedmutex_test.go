@@ -0,0 +1,95 @@
+package extsync
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var km KeyedMutex
+	km.Lock("feature-1")
+	assert.True(t, km.TryLock("feature-2"))
+	assert.False(t, km.TryLock("feature-1"))
+	assert.False(t, km.TryLockFor("feature-1", 10*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, km.LockContext(ctx, "feature-2"))
+	// 放弃等待后不会残留引用
+	assert.Equal(t, 2, km.Len())
+
+	km.Unlock("feature-1")
+	km.Unlock("feature-2")
+	assert.Equal(t, 0, km.Len())
+	assert.PanicsWithValue(t, "extsync: unlock of unlocked key feature-1", func() { km.Unlock("feature-1") })
+
+	// 只有等待者引用而没有被持有的键
+	e := km.ref("feature-3")
+	assert.PanicsWithValue(t, "extsync: unlock of unlocked key feature-3", func() { km.Unlock("feature-3") })
+	km.unref("feature-3", e)
+	assert.Equal(t, 0, km.Len())
+}
+
+func TestKeyedMutexConcurrent(t *testing.T) {
+	var (
+		km     KeyedMutex
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		counts = make(map[string]int)
+	)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("partition-%d", (g+i)%4)
+				km.Lock(key)
+				mu.Lock()
+				counts[key]++
+				n := counts[key]
+				mu.Unlock()
+				// 同一个键的临界区互斥
+				mu.Lock()
+				assert.Equal(t, n, counts[key])
+				mu.Unlock()
+				km.Unlock(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, 0, km.Len())
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	assert.Equal(t, 16*200, total)
+}
+
+func TestStripedMutex(t *testing.T) {
+	sm := NewStripedMutex(8)
+	for _, key := range []string{"a", "b", "feature-uuid", "topic-0"} {
+		h := fnv.New32a()
+		h.Write([]byte(key)) // nolint
+		assert.Equal(t, int(h.Sum32()%8), sm.Stripe(key))
+	}
+
+	sm.Lock("a")
+	assert.False(t, sm.TryLock("a"))
+	assert.False(t, sm.TryLockFor("a", 10*time.Millisecond))
+	other := "b"
+	for sm.Stripe(other) == sm.Stripe("a") {
+		other += "b"
+	}
+	assert.True(t, sm.TryLock(other))
+	sm.Unlock(other)
+	sm.Unlock("a")
+	assert.Empty(t, sm.LockContext(context.Background(), "a"))
+	sm.Unlock("a")
+
+	assert.Equal(t, 0, NewStripedMutex(0).Stripe("a"))
+}
@@ -0,0 +1,60 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+)
+
+// CountDownLatch 倒计数门闩, 计数减为0时放行所有等待者, 不可重复使用.
+type CountDownLatch struct {
+	mu    sync.Mutex
+	count int
+	done  chan struct{}
+}
+
+// NewCountDownLatch 新建初始计数为count的门闩, count不大于0时门闩直接打开.
+func NewCountDownLatch(count int) *CountDownLatch {
+	l := &CountDownLatch{
+		count: count,
+		done:  make(chan struct{}),
+	}
+	if count <= 0 {
+		l.count = 0
+		close(l.done)
+	}
+	return l
+}
+
+// CountDown 计数减1, 计数已为0时不做任何操作.
+func (l *CountDownLatch) CountDown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.count == 0 {
+		return
+	}
+	if l.count--; l.count == 0 {
+		close(l.done)
+	}
+}
+
+// Count 返回当前计数.
+func (l *CountDownLatch) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Wait 等待计数减为0, ctx被取消时放弃等待并返回ctx.Err().
+func (l *CountDownLatch) Wait(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done 返回计数减为0时被关闭的通道.
+func (l *CountDownLatch) Done() <-chan struct{} {
+	return l.done
+}
@@ -0,0 +1,34 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountDownLatch(t *testing.T) {
+	l := NewCountDownLatch(8)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, l.Wait(ctx))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Empty(t, l.Wait(context.Background()))
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		go l.CountDown()
+	}
+	wg.Wait()
+	<-l.Done()
+	assert.Equal(t, 0, l.Count())
+
+	assert.Empty(t, NewCountDownLatch(0).Wait(context.Background()))
+}
@@ -0,0 +1,495 @@
+package extsync
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/petermattis/goid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// histogramBounds 耗时直方图各个桶的上界, 最后一个桶统计超过10s的样本
+var histogramBounds = [histogramBuckets - 1]time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+const histogramBuckets = 9
+
+// HistogramBounds 返回耗时直方图各个桶的上界, 返回的是副本, 修改后不影响统计.
+func HistogramBounds() [histogramBuckets - 1]time.Duration {
+	return histogramBounds
+}
+
+// Histogram 耗时直方图
+type Histogram struct {
+	Count   int64
+	Total   time.Duration
+	Max     time.Duration
+	Buckets [histogramBuckets]int64 // Buckets[i]统计不超过HistogramBounds()[i]的样本
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	h.Count++
+	h.Total += d
+	if d > h.Max {
+		h.Max = d
+	}
+	i := sort.Search(len(histogramBounds), func(i int) bool { return d <= histogramBounds[i] })
+	h.Buckets[i]++
+}
+
+// Mean 返回平均耗时.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Total / time.Duration(h.Count)
+}
+
+// SiteStats 一个加锁位置的统计信息
+type SiteStats struct {
+	Site         string // 加锁的调用位置, 格式为"函数 (文件:行号)"
+	Acquisitions int64
+	Canceled     int64 // 放弃等待的次数
+	Wait         Histogram
+	Hold         Histogram
+}
+
+// OrderEdge 加锁顺序图中的一条边: 在Held处持有锁的情况下, 又在Acquired处获取了另一把锁.
+type OrderEdge struct {
+	Held     string
+	Acquired string
+}
+
+// Inversion 加锁顺序反转, Cycle中的边首尾相接构成环路, 并发执行时可能死锁.
+type Inversion struct {
+	Cycle []OrderEdge
+}
+
+// Deadlock 检测到的死锁, Goroutines中的协程互相等待对方持有的锁.
+type Deadlock struct {
+	Goroutines []int64
+	Sites      []string // 各协程等待锁的位置
+}
+
+// LongHold 持有锁超过阈值的告警
+type LongHold struct {
+	Site      string
+	Goroutine int64
+	Held      time.Duration
+}
+
+// ProfileOptions 锁分析配置
+type ProfileOptions struct {
+	// LongHold 持有锁超过该时长时告警, 不大于0时不告警.
+	// 除了在释放时检查, 还会每隔LongHold/2扫描一次仍被持有的锁, 每次持有只告警一次
+	LongHold time.Duration
+	// OnLongHold 告警回调, 为nil时输出告警日志
+	OnLongHold func(LongHold)
+	// Logger 输出告警和检测结果的日志, 为nil时使用zerolog的全局log.Logger
+	Logger *zerolog.Logger
+}
+
+// Report 锁分析报告
+type Report struct {
+	Sites      []SiteStats // 按总等待时长降序排列
+	Inversions []Inversion
+	Deadlocks  []Deadlock
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, s := range r.Sites {
+		fmt.Fprintf(&sb, "%s: acquisitions (%d), canceled (%d), wait mean (%v) max (%v), hold mean (%v) max (%v)\n",
+			s.Site, s.Acquisitions, s.Canceled, s.Wait.Mean(), s.Wait.Max, s.Hold.Mean(), s.Hold.Max)
+	}
+	for _, inv := range r.Inversions {
+		sb.WriteString("lock order inversion:\n")
+		for _, e := range inv.Cycle {
+			fmt.Fprintf(&sb, "\theld at %s, acquired at %s\n", e.Held, e.Acquired)
+		}
+	}
+	for _, d := range r.Deadlocks {
+		sb.WriteString("deadlock:\n")
+		for i, gid := range d.Goroutines {
+			fmt.Fprintf(&sb, "\tgoroutine (%d) waits at %s\n", gid, d.Sites[i])
+		}
+	}
+	return sb.String()
+}
+
+// heldLock 协程持有的锁
+type heldLock struct {
+	lock   uintptr
+	shared bool // 是否以共享方式(读锁)持有
+	site   string
+	since  time.Time
+	warned bool // 是否已告警持有过久
+}
+
+// waitingLock 协程正在等待的锁
+type waitingLock struct {
+	lock   uintptr
+	shared bool // 是否在等待读锁
+	site   string
+}
+
+// maxOrderLocks 加锁顺序图最多记录的锁数, 达到后不再记录新的锁的加锁顺序
+const maxOrderLocks = 1 << 16
+
+// profiler 记录Mutex和RWMutex的等待、持有时长, 以及加锁顺序和等待关系.
+// 锁以地址标识, 被回收的锁的地址可能被复用, 因此加锁顺序反转的报告可能存在误报.
+// held和waiting只记录当前持有和等待的锁, 随解锁清理; order不会随锁被回收而清理,
+// 因此以maxOrderLocks为上限, 长时间开启时可以重新调用EnableProfiling清空.
+type profiler struct {
+	opts   ProfileOptions
+	logger *zerolog.Logger
+	stop   chan struct{} // 关闭后停止扫描持有过久的锁
+
+	mu         sync.Mutex
+	sites      map[string]*SiteStats
+	held       map[int64][]heldLock
+	waiting    map[int64]waitingLock
+	order      map[uintptr]map[uintptr]OrderEdge
+	inversions []Inversion
+	deadlocks  []Deadlock
+	reported   map[string]struct{} // 已报告的环路, 避免重复报告
+}
+
+var (
+	activeProfiler atomic.Value // *profiler
+	profilingMu    sync.Mutex   // 串行化开启和关闭锁分析
+)
+
+// EnableProfiling 开启Mutex和RWMutex的锁分析, 并清空之前的统计信息.
+// 开启后每次加解锁都会记录调用位置和耗时, 开销较大, 仅用于排查问题.
+func EnableProfiling(opts ProfileOptions) {
+	p := &profiler{
+		opts:     opts,
+		logger:   opts.Logger,
+		stop:     make(chan struct{}),
+		sites:    make(map[string]*SiteStats),
+		held:     make(map[int64][]heldLock),
+		waiting:  make(map[int64]waitingLock),
+		order:    make(map[uintptr]map[uintptr]OrderEdge),
+		reported: make(map[string]struct{}),
+	}
+	if p.logger == nil {
+		p.logger = &log.Logger
+	}
+	if opts.LongHold > 0 {
+		go p.scanLongHolds()
+	}
+	profilingMu.Lock()
+	defer profilingMu.Unlock()
+	if old := loadProfiler(); old != nil {
+		close(old.stop)
+	}
+	activeProfiler.Store(p)
+}
+
+// DisableProfiling 关闭锁分析.
+func DisableProfiling() {
+	profilingMu.Lock()
+	defer profilingMu.Unlock()
+	if old := loadProfiler(); old != nil {
+		close(old.stop)
+	}
+	activeProfiler.Store((*profiler)(nil))
+}
+
+// ProfileReport 返回锁分析报告, 未开启锁分析时返回nil.
+func ProfileReport() *Report {
+	p := loadProfiler()
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := &Report{
+		Inversions: append([]Inversion(nil), p.inversions...),
+		Deadlocks:  append([]Deadlock(nil), p.deadlocks...),
+	}
+	for _, s := range p.sites {
+		r.Sites = append(r.Sites, *s)
+	}
+	sort.Slice(r.Sites, func(i, j int) bool {
+		if r.Sites[i].Wait.Total != r.Sites[j].Wait.Total {
+			return r.Sites[i].Wait.Total > r.Sites[j].Wait.Total
+		}
+		return r.Sites[i].Site < r.Sites[j].Site
+	})
+	return r
+}
+
+func loadProfiler() *profiler {
+	p, _ := activeProfiler.Load().(*profiler)
+	return p
+}
+
+var pkgPath = reflect.TypeOf(profiler{}).PkgPath()
+
+// callerSite 返回加锁的调用位置, 跳过本包中各种锁的方法.
+func callerSite() string {
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPath+".(*") || !more {
+			return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+		}
+	}
+}
+
+func (p *profiler) site(site string) *SiteStats {
+	s, ok := p.sites[site]
+	if !ok {
+		s = &SiteStats{Site: site}
+		p.sites[site] = s
+	}
+	return s
+}
+
+// acquire 通过fn阻塞地获取锁lock, 并记录等待时长, shared代表获取的是读锁.
+func (p *profiler) acquire(lock uintptr, shared bool, fn func() error) error {
+	gid, site := goid.Get(), callerSite()
+	start := time.Now()
+	p.mu.Lock()
+	p.waiting[gid] = waitingLock{lock: lock, shared: shared, site: site}
+	p.detectDeadlock(gid)
+	p.mu.Unlock()
+
+	err := fn()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.waiting, gid)
+	s := p.site(site)
+	s.Wait.observe(time.Since(start))
+	if err != nil {
+		s.Canceled++
+		return err
+	}
+	p.acquired(gid, lock, shared, site)
+	return nil
+}
+
+// tryAcquired 记录非阻塞地获取到的锁.
+func (p *profiler) tryAcquired(lock uintptr, shared bool) {
+	gid, site := goid.Get(), callerSite()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.site(site).Wait.observe(0)
+	p.acquired(gid, lock, shared, site)
+}
+
+// acquired 记录协程gid在site处获取了锁lock, 并检查加锁顺序, 调用方需持有p.mu.
+func (p *profiler) acquired(gid int64, lock uintptr, shared bool, site string) {
+	p.site(site).Acquisitions++
+	for _, h := range p.held[gid] {
+		if h.lock == lock {
+			continue
+		}
+		edges, ok := p.order[h.lock]
+		if !ok {
+			if len(p.order) >= maxOrderLocks {
+				continue
+			}
+			edges = make(map[uintptr]OrderEdge)
+			p.order[h.lock] = edges
+		}
+		if _, ok = edges[lock]; ok {
+			continue
+		}
+		edge := OrderEdge{Held: h.site, Acquired: site}
+		edges[lock] = edge
+		// 新增边h.lock->lock后, 若存在lock到h.lock的路径, 则构成环路
+		if path := p.orderPath(lock, h.lock, make(map[uintptr]bool)); path != nil {
+			p.reportInversion(append(path, edge))
+		}
+	}
+	p.held[gid] = append(p.held[gid], heldLock{lock: lock, shared: shared, site: site, since: time.Now()})
+}
+
+// orderPath 在加锁顺序图中查找from到to的路径.
+func (p *profiler) orderPath(from, to uintptr, visited map[uintptr]bool) []OrderEdge {
+	visited[from] = true
+	for next, edge := range p.order[from] {
+		if next == to {
+			return []OrderEdge{edge}
+		}
+		if visited[next] {
+			continue
+		}
+		if path := p.orderPath(next, to, visited); path != nil {
+			return append([]OrderEdge{edge}, path...)
+		}
+	}
+	return nil
+}
+
+func (p *profiler) reportInversion(cycle []OrderEdge) {
+	sites := make([]string, 0, len(cycle))
+	for _, e := range cycle {
+		sites = append(sites, e.Held+"->"+e.Acquired)
+	}
+	sort.Strings(sites)
+	key := strings.Join(sites, "|")
+	if _, ok := p.reported[key]; ok {
+		return
+	}
+	p.reported[key] = struct{}{}
+	p.inversions = append(p.inversions, Inversion{Cycle: cycle})
+	p.logger.Warn().Msgf("lock order inversion detected: %v", cycle)
+}
+
+// detectDeadlock 检查协程gid开始等待后是否形成了互相等待的环路, 调用方需持有p.mu.
+func (p *profiler) detectDeadlock(gid int64) {
+	cycle := p.waitPath(gid, gid, make(map[int64]bool))
+	if cycle == nil {
+		return
+	}
+	d := Deadlock{}
+	for _, g := range cycle {
+		d.Goroutines = append(d.Goroutines, g)
+		d.Sites = append(d.Sites, p.waiting[g].site)
+	}
+	p.deadlocks = append(p.deadlocks, d)
+	p.logger.Error().Msgf("deadlock detected: goroutines %v wait at %v", d.Goroutines, d.Sites)
+}
+
+// waitPath 查找从协程from出发, 沿"等待的锁被谁持有"回到协程to的路径.
+// 读锁的持有者不会阻塞等待读锁的协程, 因此共享等待共享不构成等待关系.
+func (p *profiler) waitPath(from, to int64, visited map[int64]bool) []int64 {
+	visited[from] = true
+	w, ok := p.waiting[from]
+	if !ok {
+		return nil
+	}
+	for holder, locks := range p.held {
+		if !blocks(locks, w) {
+			continue
+		}
+		if holder == to {
+			return []int64{from}
+		}
+		if visited[holder] {
+			continue
+		}
+		if path := p.waitPath(holder, to, visited); path != nil {
+			return append([]int64{from}, path...)
+		}
+	}
+	return nil
+}
+
+// blocks 判断持有locks的协程是否阻塞了等待w的协程.
+func blocks(locks []heldLock, w waitingLock) bool {
+	for _, h := range locks {
+		if h.lock == w.lock && !(h.shared && w.shared) {
+			return true
+		}
+	}
+	return false
+}
+
+// release 记录锁lock被释放, 优先匹配当前协程持有的锁, Mutex也可以由其他协程释放.
+func (p *profiler) release(lock uintptr) {
+	gid := goid.Get()
+	p.mu.Lock()
+	h, ok := p.removeHeld(gid, lock)
+	if !ok {
+		for other := range p.held {
+			if h, ok = p.removeHeld(other, lock); ok {
+				gid = other
+				break
+			}
+		}
+	}
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	d := time.Since(h.since)
+	p.site(h.site).Hold.observe(d)
+	p.mu.Unlock()
+
+	// 扫描时已经告警过的不再重复告警
+	if p.opts.LongHold > 0 && d > p.opts.LongHold && !h.warned {
+		p.longHold(LongHold{Site: h.site, Goroutine: gid, Held: d})
+	}
+}
+
+// scanLongHolds 定期扫描仍被持有的锁, 使一直不释放的锁也能被告警.
+func (p *profiler) scanLongHolds() {
+	interval := p.opts.LongHold / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			var holds []LongHold
+			p.mu.Lock()
+			for gid, locks := range p.held {
+				for i := range locks {
+					h := &locks[i]
+					if d := now.Sub(h.since); !h.warned && d > p.opts.LongHold {
+						h.warned = true
+						holds = append(holds, LongHold{Site: h.site, Goroutine: gid, Held: d})
+					}
+				}
+			}
+			p.mu.Unlock()
+			for _, lh := range holds {
+				p.longHold(lh)
+			}
+		}
+	}
+}
+
+func (p *profiler) longHold(lh LongHold) {
+	if p.opts.OnLongHold != nil {
+		p.opts.OnLongHold(lh)
+		return
+	}
+	p.logger.Warn().Msgf("lock acquired at %s has been held by goroutine (%d) for %v", lh.Site, lh.Goroutine, lh.Held)
+}
+
+// removeHeld 移除协程gid最近一次获取的锁lock, 调用方需持有p.mu.
+func (p *profiler) removeHeld(gid int64, lock uintptr) (heldLock, bool) {
+	locks := p.held[gid]
+	for i := len(locks) - 1; i >= 0; i-- {
+		if locks[i].lock != lock {
+			continue
+		}
+		h := locks[i]
+		locks = append(locks[:i], locks[i+1:]...)
+		if len(locks) == 0 {
+			delete(p.held, gid)
+		} else {
+			p.held[gid] = locks
+		}
+		return h, true
+	}
+	return heldLock{}, false
+}
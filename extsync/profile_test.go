@@ -0,0 +1,222 @@
+package extsync
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedBuffer 可以被日志输出和测试并发读写的缓冲区
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestProfileSites(t *testing.T) {
+	assert.Nil(t, ProfileReport())
+	var (
+		mu        sync.Mutex
+		longHolds []LongHold
+	)
+	EnableProfiling(ProfileOptions{
+		LongHold: 10 * time.Millisecond,
+		OnLongHold: func(lh LongHold) {
+			mu.Lock()
+			longHolds = append(longHolds, lh)
+			mu.Unlock()
+		},
+	})
+	defer DisableProfiling()
+
+	var m Mutex
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		m.Lock() // 等待
+		m.Unlock()
+		close(done)
+	}()
+	waitCount(&m, 2)
+	time.Sleep(20 * time.Millisecond)
+	m.Unlock()
+	<-done
+
+	var rw RWMutex
+	assert.True(t, rw.TryRLock())
+	rw.RUnlock()
+
+	r := ProfileReport()
+	assert.Len(t, r.Sites, 3)
+	// 按总等待时长排序, 等待最久的是协程中的加锁位置
+	assert.Contains(t, r.Sites[0].Site, "TestProfileSites.func2")
+	assert.GreaterOrEqual(t, int64(r.Sites[0].Wait.Max), int64(20*time.Millisecond))
+	assert.Equal(t, int64(1), r.Sites[0].Wait.Buckets[5])
+	// 主协程中的加锁位置持有锁最久
+	var s *SiteStats
+	for i := range r.Sites {
+		if s == nil || r.Sites[i].Hold.Max > s.Hold.Max {
+			s = &r.Sites[i]
+		}
+	}
+	assert.Contains(t, s.Site, "TestProfileSites (")
+	assert.Equal(t, int64(1), s.Acquisitions)
+	assert.GreaterOrEqual(t, int64(s.Hold.Max), int64(20*time.Millisecond))
+	mu.Lock()
+	assert.Len(t, longHolds, 1)
+	assert.Equal(t, s.Site, longHolds[0].Site)
+	mu.Unlock()
+	assert.NotEmpty(t, r.String())
+}
+
+func TestProfileLongHoldScan(t *testing.T) {
+	buf := new(lockedBuffer)
+	logger := zerolog.New(buf)
+	EnableProfiling(ProfileOptions{LongHold: 10 * time.Millisecond, Logger: &logger})
+	defer DisableProfiling()
+
+	// 一直不释放的锁同样会被告警
+	var m Mutex
+	m.Lock()
+	defer m.Unlock()
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "TestProfileLongHoldScan")
+	}, time.Second, time.Millisecond)
+	// 每次持有只告警一次
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+}
+
+func TestProfileInversion(t *testing.T) {
+	EnableProfiling(ProfileOptions{})
+	defer DisableProfiling()
+
+	var a, b Mutex
+	var c RWMutex
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	assert.Empty(t, ProfileReport().Inversions)
+
+	// 不同协程以相反的顺序加锁, 经由c形成环路a->b->c->a
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b.Lock()
+		c.RLock()
+		c.RUnlock()
+		b.Unlock()
+		c.Lock()
+		a.Lock()
+		a.Unlock()
+		c.Unlock()
+	}()
+	wg.Wait()
+
+	r := ProfileReport()
+	assert.Len(t, r.Inversions, 1)
+	assert.Len(t, r.Inversions[0].Cycle, 3)
+	assert.Empty(t, r.Deadlocks)
+}
+
+func TestProfileDeadlock(t *testing.T) {
+	EnableProfiling(ProfileOptions{})
+	defer DisableProfiling()
+
+	var a, b Mutex
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	lock := func(first, second *Mutex) {
+		defer wg.Done()
+		first.Lock()
+		defer first.Unlock()
+		<-start
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if second.LockContext(ctx) == nil {
+			second.Unlock()
+		}
+	}
+	wg.Add(2)
+	go lock(&a, &b)
+	go lock(&b, &a)
+	waitCount(&a, 1)
+	waitCount(&b, 1)
+	close(start)
+	wg.Wait()
+
+	r := ProfileReport()
+	assert.Len(t, r.Deadlocks, 1)
+	assert.Len(t, r.Deadlocks[0].Goroutines, 2)
+	// 先超时的协程放弃等待后, 另一个协程可能获取到锁
+	var canceled, waits int64
+	for _, s := range r.Sites {
+		canceled += s.Canceled
+		waits += s.Wait.Count
+	}
+	assert.True(t, canceled == 1 || canceled == 2)
+	assert.Equal(t, int64(4), waits)
+}
+
+func TestProfileSharedWait(t *testing.T) {
+	for _, c := range []struct {
+		shared    [2]bool
+		deadlocks int
+	}{
+		{shared: [2]bool{true, true}, deadlocks: 0},
+		{shared: [2]bool{false, true}, deadlocks: 0},
+		{shared: [2]bool{false, false}, deadlocks: 1},
+	} {
+		EnableProfiling(ProfileOptions{})
+		var (
+			a, b           RWMutex
+			wg, held, wait sync.WaitGroup
+		)
+		held.Add(2)
+		wait.Add(2)
+		lock := func(first, second *RWMutex, shared bool) {
+			defer wg.Done()
+			first.RLock()
+			defer first.RUnlock()
+			held.Done()
+			held.Wait()
+			// 两个协程同时处于等待状态后再放弃, 使等待图中同时存在两条等待边
+			second.acquire(shared, func() error { // nolint
+				wait.Done()
+				wait.Wait()
+				return context.Canceled
+			})
+		}
+		wg.Add(2)
+		go lock(&a, &b, c.shared[0])
+		go lock(&b, &a, c.shared[1])
+		wg.Wait()
+		assert.Len(t, ProfileReport().Deadlocks, c.deadlocks, "%v", c.shared)
+		DisableProfiling()
+	}
+}
+
+func TestHistogramBounds(t *testing.T) {
+	bounds := HistogramBounds()
+	bounds[0] = time.Hour
+	assert.Equal(t, time.Microsecond, HistogramBounds()[0])
+}
@@ -1,57 +1,118 @@
 package extsync
 
 import (
+	"context"
 	"fmt"
-	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/petermattis/goid"
 )
 
+// Token 可重入锁的持有者标识, 用于不依赖goroutine id的令牌模式.
+// 同一个令牌可以在多个协程间传递, 持有相同令牌的调用被视为同一个持有者.
+type Token int64
+
+var tokenSeq int64
+
+// NewToken 生成一个新的令牌, 令牌均为负数, 不会与goroutine id冲突.
+func NewToken() Token {
+	return Token(-atomic.AddInt64(&tokenSeq, 1))
+}
+
 // ReentrantMutex 可重入锁
 type ReentrantMutex struct {
-	sync.Mutex
+	mu Mutex
 
-	owner     int64 // 当前持有锁的goroutine id
+	owner     int64 // 当前持有锁的goroutine id或令牌, 0代表没有持有者
 	recursion int32 // 重入次数
 }
 
 // Info 返回锁的拥有者和重入次数
 func (m *ReentrantMutex) Info() string {
-	return fmt.Sprintf("owner (%d), recursion (%d)", m.owner, m.recursion)
+	return fmt.Sprintf("owner (%d), recursion (%d)", atomic.LoadInt64(&m.owner), atomic.LoadInt32(&m.recursion))
 }
 
 // Lock 可重入锁加锁
 func (m *ReentrantMutex) Lock() {
-	gid := goid.Get()
-	// 如果这个goroutine就是当前持有锁的goroutine, 则允许重入
-	if atomic.LoadInt64(&m.owner) == gid {
-		// 重入次数加一（非临界区，不需要原子操作）
-		m.recursion++
-		return
+	m.lock(context.Background(), goid.Get()) // nolint
+}
+
+// LockContext 可重入锁加锁, ctx被取消时放弃等待并返回ctx.Err(); 重入时总是立即成功.
+func (m *ReentrantMutex) LockContext(ctx context.Context) error {
+	return m.lock(ctx, goid.Get())
+}
+
+// TryLockFor 尝试在d时间内获取可重入锁, d不大于0时不等待.
+func (m *ReentrantMutex) TryLockFor(d time.Duration) bool {
+	if d <= 0 {
+		return m.TryLock()
 	}
-	// 首次获取锁
-	m.Mutex.Lock()
-	// 首次获取时记录下goroutine id
-	atomic.StoreInt64(&m.owner, gid)
-	// 首次获取时重入次数置为1（非临界区，不需要原子操作）
-	m.recursion = 1
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return m.LockContext(ctx) == nil
+}
+
+// TryLock 尝试获取可重入锁(非阻塞), 重入时总是成功.
+func (m *ReentrantMutex) TryLock() bool {
+	id := goid.Get()
+	if atomic.LoadInt64(&m.owner) == id {
+		atomic.AddInt32(&m.recursion, 1)
+		return true
+	}
+	if !m.mu.TryLock() {
+		return false
+	}
+	m.own(id)
+	return true
 }
 
 // Unlock 可重入锁解锁
 func (m *ReentrantMutex) Unlock() {
-	gid := goid.Get()
+	m.unlock(goid.Get())
+}
+
+// LockToken 以令牌t的身份加锁, ctx被取消时放弃等待并返回ctx.Err().
+func (m *ReentrantMutex) LockToken(ctx context.Context, t Token) error {
+	return m.lock(ctx, int64(t))
+}
+
+// UnlockToken 以令牌t的身份解锁.
+func (m *ReentrantMutex) UnlockToken(t Token) {
+	m.unlock(int64(t))
+}
+
+func (m *ReentrantMutex) lock(ctx context.Context, id int64) error {
+	// 如果这个goroutine就是当前持有锁的goroutine, 则允许重入.
+	// 只有持有者自己会把owner设置为自己的id, 因此这里的判断不存在竞争
+	if atomic.LoadInt64(&m.owner) == id {
+		atomic.AddInt32(&m.recursion, 1)
+		return nil
+	}
+	// 首次获取锁
+	if err := m.mu.LockContext(ctx); err != nil {
+		return err
+	}
+	m.own(id)
+	return nil
+}
+
+// own 首次获取锁时记录下持有者, 并将重入次数置为1.
+func (m *ReentrantMutex) own(id int64) {
+	atomic.StoreInt32(&m.recursion, 1)
+	atomic.StoreInt64(&m.owner, id)
+}
+
+func (m *ReentrantMutex) unlock(id int64) {
 	// 非持有锁的goroutine尝试释放锁, 并不允许释放
-	if atomic.LoadInt64(&m.owner) != gid {
-		panic(fmt.Sprintf("goroutine (%d) does not hold the current lock, but the owner (%d) does", m.owner, gid))
+	if owner := atomic.LoadInt64(&m.owner); owner != id {
+		panic(fmt.Sprintf("goroutine (%d) does not hold the current lock, but the owner (%d) does", id, owner))
 	}
-	// 重入次数减1
-	m.recursion--
 	// 如果这个goroutine还未完全释放锁, 则直接返回
-	if m.recursion != 0 {
+	if atomic.AddInt32(&m.recursion, -1) != 0 {
 		return
 	}
 	// 此goroutine最后一次调用, 需要释放锁
-	atomic.StoreInt64(&m.owner, -1)
-	m.Mutex.Unlock()
+	atomic.StoreInt64(&m.owner, 0)
+	m.mu.Unlock()
 }
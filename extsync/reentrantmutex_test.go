@@ -1,8 +1,14 @@
 package extsync
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/petermattis/goid"
+	"github.com/stretchr/testify/assert"
 )
 
 type fakeCounter struct {
@@ -27,3 +33,65 @@ func TestReentrantMutex(t *testing.T) {
 	go reentrant(t, 0)
 	time.Sleep(2 * time.Second)
 }
+
+func TestReentrantMutexUnlockByOther(t *testing.T) {
+	var m ReentrantMutex
+	m.Lock()
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+		m.Unlock()
+	}()
+	msg := (<-done).(string)
+	// 先报告调用者, 再报告持有者
+	assert.Contains(t, msg, fmt.Sprintf("but the owner (%d) does", goid.Get()))
+	m.Unlock()
+}
+
+func TestReentrantMutexToken(t *testing.T) {
+	var m ReentrantMutex
+	tok := NewToken()
+	assert.True(t, tok < 0)
+	assert.NotEqual(t, tok, NewToken())
+
+	assert.Empty(t, m.LockToken(context.Background(), tok))
+	// 令牌可以在协程间传递
+	done := make(chan struct{})
+	go func() {
+		assert.Empty(t, m.LockToken(context.Background(), tok))
+		m.UnlockToken(tok)
+		close(done)
+	}()
+	<-done
+	assert.False(t, m.TryLockFor(10*time.Millisecond))
+	m.UnlockToken(tok)
+	assert.True(t, m.TryLock())
+	m.Unlock()
+}
+
+func TestReentrantMutexRace(t *testing.T) {
+	var (
+		m     ReentrantMutex
+		wg    sync.WaitGroup
+		count int
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				m.Lock()
+				m.Lock()
+				count++
+				_ = m.Info()
+				m.Unlock()
+				m.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		_ = m.Info()
+	}
+	wg.Wait()
+	assert.Equal(t, 1600, count)
+}
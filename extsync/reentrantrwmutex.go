@@ -0,0 +1,176 @@
+package extsync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/petermattis/goid"
+)
+
+// ReentrantRWMutex 可重入读写锁, 零值可用.
+// 持有写锁的goroutine可以再次获取读锁或写锁, 持有读锁的goroutine可以再次获取读锁,
+// 重入的读锁不会被等待中的写者阻塞. 持有读锁时获取写锁(升级)会导致死锁, 因此直接panic.
+// 释放写锁时若仍持有重入的读锁, 底层的写锁会保留到这些读锁释放为止, 其间其他读者仍被阻塞.
+type ReentrantRWMutex struct {
+	rw RWMutex
+
+	mu      sync.Mutex
+	writer  int64           // 持有写锁的goroutine id或令牌, 0代表没有持有者
+	writes  int32           // 写锁重入次数
+	held    int64           // 已释放写锁但仍以重入的读锁占用底层写锁的持有者, 0代表没有
+	readers map[int64]int32 // 各持有者的读锁重入次数, 包括写锁持有者重入的读锁
+}
+
+// Lock 获取写锁.
+func (m *ReentrantRWMutex) Lock() {
+	m.lock(context.Background(), goid.Get()) // nolint
+}
+
+// LockContext 获取写锁, ctx被取消时放弃等待并返回ctx.Err(); 重入时总是立即成功.
+func (m *ReentrantRWMutex) LockContext(ctx context.Context) error {
+	return m.lock(ctx, goid.Get())
+}
+
+// Unlock 释放写锁.
+func (m *ReentrantRWMutex) Unlock() {
+	m.unlock(goid.Get())
+}
+
+// RLock 获取读锁.
+func (m *ReentrantRWMutex) RLock() {
+	m.rlock(context.Background(), goid.Get()) // nolint
+}
+
+// RLockContext 获取读锁, ctx被取消时放弃等待并返回ctx.Err(); 重入时总是立即成功.
+func (m *ReentrantRWMutex) RLockContext(ctx context.Context) error {
+	return m.rlock(ctx, goid.Get())
+}
+
+// RUnlock 释放读锁.
+func (m *ReentrantRWMutex) RUnlock() {
+	m.runlock(goid.Get())
+}
+
+// LockToken 以令牌t的身份获取写锁.
+func (m *ReentrantRWMutex) LockToken(ctx context.Context, t Token) error {
+	return m.lock(ctx, int64(t))
+}
+
+// UnlockToken 以令牌t的身份释放写锁.
+func (m *ReentrantRWMutex) UnlockToken(t Token) {
+	m.unlock(int64(t))
+}
+
+// RLockToken 以令牌t的身份获取读锁.
+func (m *ReentrantRWMutex) RLockToken(ctx context.Context, t Token) error {
+	return m.rlock(ctx, int64(t))
+}
+
+// RUnlockToken 以令牌t的身份释放读锁.
+func (m *ReentrantRWMutex) RUnlockToken(t Token) {
+	m.runlock(int64(t))
+}
+
+// Info 返回写锁的拥有者、写锁重入次数和读锁持有者数.
+func (m *ReentrantRWMutex) Info() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fmt.Sprintf("writer (%d), recursion (%d), readers (%d)", m.writer, m.writes, len(m.readers))
+}
+
+func (m *ReentrantRWMutex) lock(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	if m.writer == id {
+		m.writes++
+		m.mu.Unlock()
+		return nil
+	}
+	if m.held == id {
+		// 底层写锁仍被自己占用, 直接重新成为写者
+		m.writer, m.writes, m.held = id, 1, 0
+		m.mu.Unlock()
+		return nil
+	}
+	if m.readers[id] > 0 {
+		m.mu.Unlock()
+		panic(fmt.Sprintf("goroutine (%d) holds the read lock and can not upgrade it to the write lock", id))
+	}
+	m.mu.Unlock()
+
+	if err := m.rw.LockContext(ctx); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.writer, m.writes = id, 1
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *ReentrantRWMutex) unlock(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.writer != id {
+		panic(fmt.Sprintf("goroutine (%d) does not hold the write lock, but the owner (%d) does", id, m.writer))
+	}
+	if m.writes--; m.writes > 0 {
+		return
+	}
+	m.writer = 0
+	if m.readers[id] > 0 {
+		m.held = id
+		return
+	}
+	m.rw.Unlock()
+}
+
+func (m *ReentrantRWMutex) rlock(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	if m.readers == nil {
+		m.readers = make(map[int64]int32)
+	}
+	if m.writer == id || m.readers[id] > 0 {
+		// 重入的读锁不需要再次获取, 否则会被等待中的写者阻塞而死锁
+		m.readers[id]++
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	if err := m.rw.RLockContext(ctx); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readers[id] > 0 {
+		// 持有相同令牌的其他协程已同时获取了读锁, 归还多获取的一份
+		m.readers[id]++
+		m.rw.RUnlock()
+		return nil
+	}
+	m.readers[id] = 1
+	return nil
+}
+
+func (m *ReentrantRWMutex) runlock(id int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.readers[id]
+	if n == 0 {
+		panic(fmt.Sprintf("goroutine (%d) does not hold the read lock", id))
+	}
+	if n > 1 {
+		m.readers[id] = n - 1
+		return
+	}
+	delete(m.readers, id)
+	// 写锁持有者重入的读锁并未真正获取读锁
+	switch id {
+	case m.writer:
+	case m.held:
+		m.held = 0
+		m.rw.Unlock()
+	default:
+		m.rw.RUnlock()
+	}
+}
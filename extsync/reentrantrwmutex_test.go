@@ -0,0 +1,145 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReentrantRWMutex(t *testing.T) {
+	var m ReentrantRWMutex
+
+	// 写锁持有者可以重入读锁和写锁
+	m.Lock()
+	m.Lock()
+	m.RLock()
+	assert.False(t, m.rw.TryRLock())
+	m.Unlock()
+	m.RUnlock()
+	m.Unlock()
+	assert.True(t, m.rw.TryLock())
+	m.rw.Unlock()
+
+	// 等待中的写者不会阻塞重入的读者
+	m.RLock()
+	writer := make(chan struct{})
+	go func() {
+		m.Lock()
+		close(writer)
+		m.Unlock()
+	}()
+	for m.rw.TryRLock() {
+		m.rw.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	m.RLock()
+	m.RUnlock()
+	m.RUnlock()
+	<-writer
+
+	// 升级会panic
+	m.RLock()
+	assert.Panics(t, func() { m.Lock() })
+	m.RUnlock()
+	assert.Panics(t, func() { m.RUnlock() })
+}
+
+func TestReentrantRWMutexDowngrade(t *testing.T) {
+	var m ReentrantRWMutex
+	m.Lock()
+	m.RLock()
+	m.Unlock()
+	// 重入的读锁释放之前底层写锁仍被占用
+	assert.True(t, m.rw.WExist())
+	assert.False(t, m.rw.TryRLock())
+	m.RLock()
+	m.RUnlock()
+	// 可以重新获取写锁
+	m.Lock()
+	m.Unlock()
+	m.RUnlock()
+	assert.True(t, m.rw.TryLock())
+	m.rw.Unlock()
+}
+
+func TestReentrantRWMutexToken(t *testing.T) {
+	var m ReentrantRWMutex
+	tok := NewToken()
+	assert.Empty(t, m.LockToken(context.Background(), tok))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Empty(t, m.RLockToken(context.Background(), tok))
+		m.RUnlockToken(tok)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, m.RLockContext(ctx))
+	}()
+	<-done
+	m.UnlockToken(tok)
+}
+
+func TestReentrantRWMutexTokenConcurrent(t *testing.T) {
+	var (
+		m  ReentrantRWMutex
+		wg sync.WaitGroup
+	)
+	tok := NewToken()
+	// 所有协程都在底层读锁上等待, 被唤醒后同时记录同一个令牌
+	m.rw.Lock()
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Empty(t, m.RLockToken(context.Background(), tok))
+		}()
+	}
+	for m.rw.RCount() != 16 {
+		time.Sleep(time.Millisecond)
+	}
+	m.rw.Unlock()
+	wg.Wait()
+	assert.Equal(t, int32(1), m.rw.RCount())
+	for i := 0; i < 16; i++ {
+		m.RUnlockToken(tok)
+	}
+	// 没有泄漏的读锁, 写者可以获取锁
+	assert.True(t, m.rw.TryLock())
+	m.rw.Unlock()
+}
+
+func TestReentrantRWMutexStress(t *testing.T) {
+	var (
+		m     ReentrantRWMutex
+		wg    sync.WaitGroup
+		count int
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if (g+i)%2 == 0 {
+					m.Lock()
+					m.RLock()
+					count++
+					m.RUnlock()
+					m.Unlock()
+				} else {
+					m.RLock()
+					m.RLock()
+					_ = count
+					m.RUnlock()
+					m.RUnlock()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, 800, count)
+	_ = m.Info()
+}
@@ -0,0 +1,105 @@
+package extsync
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// semaWaiter 等待获取信号量的协程
+type semaWaiter struct {
+	n     int64
+	ready chan struct{} // 获取成功后关闭
+}
+
+// fifoSema 按FIFO顺序分配的带权信号量, 零值可用, 容量由调用方传入.
+// 等待者按到达顺序获取, 队首等待者未被满足时后来者不会插队, 因此权重较大的等待者不会被源源不断的小权重请求饿死.
+// 等待中的协程可以通过context放弃等待, 放弃后立即从等待队列中移除.
+type fifoSema struct {
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List
+}
+
+func (s *fifoSema) acquire(ctx context.Context, n, size int64) error {
+	s.mu.Lock()
+	if size-s.cur >= n && s.waiters.Len() == 0 {
+		// fast path
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(semaWaiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// 在放弃的同时获取成功, 归还后仍然返回错误
+			s.cur -= n
+			s.notify(size)
+		default:
+			front := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// 队首等待者离开后, 其后的等待者可能已经可以被满足
+			if front {
+				s.notify(size)
+			}
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *fifoSema) tryAcquire(n, size int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+func (s *fifoSema) release(n, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.cur {
+		panic("extsync: semaphore released more than held")
+	}
+	s.cur -= n
+	s.notify(size)
+}
+
+// notify 按顺序唤醒可以被满足的等待者, 调用方需持有s.mu.
+func (s *fifoSema) notify(size int64) {
+	for {
+		elem := s.waiters.Front()
+		if elem == nil {
+			return
+		}
+		w := elem.Value.(semaWaiter)
+		if size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(elem)
+		close(w.ready)
+	}
+}
+
+// state 返回已分配的数量和等待者数.
+func (s *fifoSema) state() (int64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur, s.waiters.Len()
+}
@@ -0,0 +1,53 @@
+package extsync
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWeightTooLarge 申请的权重超过了信号量的容量
+var ErrWeightTooLarge = errors.New("extsync: weight exceeds semaphore size")
+
+// Semaphore 带权信号量, 等待者按FIFO顺序获取, 权重较大的等待者不会被权重较小的后来者饿死.
+type Semaphore struct {
+	size int64
+	sema fifoSema
+}
+
+// NewSemaphore 新建容量为size的带权信号量.
+func NewSemaphore(size int64) *Semaphore {
+	return &Semaphore{size: size}
+}
+
+// Acquire 获取权重n, ctx被取消时放弃等待并返回ctx.Err(); n为负数时panic.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	checkWeight(n)
+	if n > s.size {
+		return ErrWeightTooLarge
+	}
+	return s.sema.acquire(ctx, n, s.size)
+}
+
+// TryAcquire 尝试获取权重n(非阻塞), n为负数时panic.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	checkWeight(n)
+	return s.sema.tryAcquire(n, s.size)
+}
+
+// Release 释放权重n, n为负数或超过已获取的权重时panic.
+func (s *Semaphore) Release(n int64) {
+	checkWeight(n)
+	s.sema.release(n, s.size)
+}
+
+// Available 返回当前可用的权重.
+func (s *Semaphore) Available() int64 {
+	cur, _ := s.sema.state()
+	return s.size - cur
+}
+
+func checkWeight(n int64) {
+	if n < 0 {
+		panic("extsync: negative semaphore weight")
+	}
+}
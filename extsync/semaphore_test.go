@@ -0,0 +1,75 @@
+package extsync
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(10)
+	assert.Equal(t, ErrWeightTooLarge, s.Acquire(context.Background(), 11))
+	assert.Empty(t, s.Acquire(context.Background(), 6))
+	assert.True(t, s.TryAcquire(4))
+	assert.False(t, s.TryAcquire(1))
+	assert.Equal(t, int64(0), s.Available())
+
+	// 权重较大的等待者在队首时, 后来者不能插队
+	big := make(chan error)
+	go func() { big <- s.Acquire(context.Background(), 8) }()
+	for {
+		if _, waiters := s.sema.state(); waiters == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Release(4)
+	assert.False(t, s.TryAcquire(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.Acquire(ctx, 1))
+	s.Release(6)
+	assert.Empty(t, <-big)
+	assert.Equal(t, int64(2), s.Available())
+	s.Release(8)
+	assert.PanicsWithValue(t, "extsync: semaphore released more than held", func() { s.Release(1) })
+	assert.Equal(t, int64(10), s.Available())
+	assert.PanicsWithValue(t, "extsync: negative semaphore weight", func() { s.Release(-1) })
+	assert.PanicsWithValue(t, "extsync: negative semaphore weight", func() { s.TryAcquire(-1) })
+	assert.PanicsWithValue(t, "extsync: negative semaphore weight", func() { s.Acquire(context.Background(), -1) }) // nolint
+}
+
+func TestSemaphoreRace(t *testing.T) {
+	const size = 5
+	s := NewSemaphore(size)
+	var (
+		wg      sync.WaitGroup
+		current int64
+	)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			n := int64(g%3 + 1)
+			for i := 0; i < 100; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				err := s.Acquire(ctx, n)
+				cancel()
+				if err != nil {
+					continue
+				}
+				if c := atomic.AddInt64(&current, n); c > size {
+					t.Errorf("%d weights are acquired", c)
+				}
+				atomic.AddInt64(&current, -n)
+				s.Release(n)
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(size), s.Available())
+}
@@ -0,0 +1,96 @@
+package fasttypeconversion
+
+import (
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// 检查模式下最多跟踪的转换次数, 超出后覆盖最早的记录
+const __MaxTracked = 4096
+
+// MutationError 零拷贝转换后底层数据被修改
+type MutationError struct {
+	Op   string // 发生修改的转换, String2Bytes或Bytes2String
+	Site string // 转换的调用位置
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("data converted by %s at %s was mutated", e.Op, e.Site)
+}
+
+// tracked 检查模式下记录的一次转换
+type tracked struct {
+	data []byte // 同时保证底层数据在检查前不被回收
+	sum  uint64
+	op   string
+	site string
+}
+
+var (
+	checking  int32
+	trackMu   sync.Mutex
+	trackRing []tracked
+	trackNext int
+)
+
+// EnableCheck 开启检查模式: 记录每次转换时底层数据的校验和, 由Verify检查其后是否被修改.
+// 检查模式会计算校验和并延长底层数据的生命周期, 仅用于测试和排查问题.
+func EnableCheck() {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	trackRing, trackNext = make([]tracked, 0, __MaxTracked), 0
+	atomic.StoreInt32(&checking, 1)
+}
+
+// DisableCheck 关闭检查模式, 并丢弃已记录的转换.
+func DisableCheck() {
+	atomic.StoreInt32(&checking, 0)
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	trackRing, trackNext = nil, 0
+}
+
+// Verify 检查记录的转换中是否有底层数据被修改, 返回第一个被修改的转换对应的*MutationError.
+// 报告过的修改不会被重复报告.
+func Verify() error {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	for i := range trackRing {
+		t := &trackRing[i]
+		if sum := checksum(t.data); sum != t.sum {
+			t.sum = sum
+			return &MutationError{Op: t.op, Site: t.site}
+		}
+	}
+	return nil
+}
+
+func checkEnabled() bool {
+	return atomic.LoadInt32(&checking) == 1
+}
+
+func track(b []byte, op string) {
+	site := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		site = fmt.Sprintf("%s:%d", file, line)
+	}
+	t := tracked{data: b, sum: checksum(b), op: op, site: site}
+
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	if len(trackRing) < __MaxTracked {
+		trackRing = append(trackRing, t)
+		return
+	}
+	trackRing[trackNext] = t
+	trackNext = (trackNext + 1) % __MaxTracked
+}
+
+func checksum(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b) // nolint
+	return h.Sum64()
+}
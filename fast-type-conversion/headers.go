@@ -0,0 +1,19 @@
+//go:build !go1.20
+// +build !go1.20
+
+package fasttypeconversion
+
+import "unsafe"
+
+// stringHeader和sliceHeader与运行时中字符串和切片的内存布局一致.
+// 与reflect.StringHeader不同, 数据指针使用unsafe.Pointer, 因此能被GC正确跟踪.
+type stringHeader struct {
+	data unsafe.Pointer
+	len  int
+}
+
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
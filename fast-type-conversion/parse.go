@@ -0,0 +1,57 @@
+package fasttypeconversion
+
+import "strconv"
+
+// ParseInt 与strconv.ParseInt相同, 但直接解析字节数组, 解析成功时不分配内存.
+// 出错时返回*strconv.NumError, 其Num为b的拷贝, 之后修改b不会影响错误信息.
+// 本文件中的解析函数不保留对b的引用, 因此不受EnableCheck跟踪, 返回后即可复用b.
+func ParseInt(b []byte, base int, bitSize int) (int64, error) {
+	i, err := strconv.ParseInt(bytes2String(b), base, bitSize)
+	return i, detach(err, b)
+}
+
+// ParseUint 与strconv.ParseUint相同, 但直接解析字节数组, 解析成功时不分配内存.
+func ParseUint(b []byte, base int, bitSize int) (uint64, error) {
+	u, err := strconv.ParseUint(bytes2String(b), base, bitSize)
+	return u, detach(err, b)
+}
+
+// ParseFloat 与strconv.ParseFloat相同, 但直接解析字节数组, 解析成功时不分配内存.
+func ParseFloat(b []byte, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(bytes2String(b), bitSize)
+	return f, detach(err, b)
+}
+
+// ParseBool 与strconv.ParseBool相同, 但直接解析字节数组, 解析成功时不分配内存.
+func ParseBool(b []byte) (bool, error) {
+	v, err := strconv.ParseBool(bytes2String(b))
+	return v, detach(err, b)
+}
+
+// detach 将错误中引用b的字符串替换为拷贝.
+func detach(err error, b []byte) error {
+	if ne, ok := err.(*strconv.NumError); ok {
+		ne.Num = string(b)
+	}
+	return err
+}
+
+// AppendInt 将i按base进制格式化后追加到dst, 与strconv.AppendInt相同.
+func AppendInt(dst []byte, i int64, base int) []byte {
+	return strconv.AppendInt(dst, i, base)
+}
+
+// AppendUint 将u按base进制格式化后追加到dst, 与strconv.AppendUint相同.
+func AppendUint(dst []byte, u uint64, base int) []byte {
+	return strconv.AppendUint(dst, u, base)
+}
+
+// AppendFloat 将f格式化后追加到dst, 参数含义与strconv.AppendFloat相同.
+func AppendFloat(dst []byte, f float64, fmt byte, prec, bitSize int) []byte {
+	return strconv.AppendFloat(dst, f, fmt, prec, bitSize)
+}
+
+// AppendBool 将v格式化为"true"或"false"后追加到dst.
+func AppendBool(dst []byte, v bool) []byte {
+	return strconv.AppendBool(dst, v)
+}
@@ -0,0 +1,81 @@
+//go:build go1.18
+// +build go1.18
+
+package fasttypeconversion
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// sameError 判断两个错误是否具有相同的strconv语义.
+func sameError(t *testing.T, got, want error) {
+	if (got == nil) != (want == nil) {
+		t.Fatalf("got error %v, want %v", got, want)
+	}
+	if got != nil && got.Error() != want.Error() {
+		t.Fatalf("got error %q, want %q", got, want)
+	}
+}
+
+func FuzzParseInt(f *testing.F) {
+	for _, s := range []string{"0", "-9223372036854775808", "0x1f", "0b101", "1_000", "+", "99999999999999999999"} {
+		f.Add(s, 0, 64)
+	}
+	f.Fuzz(func(t *testing.T, s string, base int, bitSize int) {
+		want, wantErr := strconv.ParseInt(s, base, bitSize)
+		got, err := ParseInt([]byte(s), base, bitSize)
+		if got != want {
+			t.Fatalf("ParseInt(%q, %d, %d) = %d, want %d", s, base, bitSize, got, want)
+		}
+		sameError(t, err, wantErr)
+
+		wantU, wantErr := strconv.ParseUint(s, base, bitSize)
+		gotU, err := ParseUint([]byte(s), base, bitSize)
+		if gotU != wantU {
+			t.Fatalf("ParseUint(%q, %d, %d) = %d, want %d", s, base, bitSize, gotU, wantU)
+		}
+		sameError(t, err, wantErr)
+	})
+}
+
+func FuzzParseFloat(f *testing.F) {
+	for _, s := range []string{"0", "-1.5e-10", "inf", "NaN", "0x1p-2", "1e400", "."} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, bitSize := range []int{32, 64} {
+			want, wantErr := strconv.ParseFloat(s, bitSize)
+			got, err := ParseFloat([]byte(s), bitSize)
+			if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+				t.Fatalf("ParseFloat(%q, %d) = %v, want %v", s, bitSize, got, want)
+			}
+			sameError(t, err, wantErr)
+		}
+		wantB, wantErr := strconv.ParseBool(s)
+		gotB, err := ParseBool([]byte(s))
+		if gotB != wantB {
+			t.Fatalf("ParseBool(%q) = %v, want %v", s, gotB, wantB)
+		}
+		sameError(t, err, wantErr)
+	})
+}
+
+func FuzzAppend(f *testing.F) {
+	f.Add(int64(-1), 1.5, 10)
+	f.Fuzz(func(t *testing.T, i int64, x float64, base int) {
+		if base < 2 || base > 36 {
+			return
+		}
+		if got, want := string(AppendInt(nil, i, base)), strconv.FormatInt(i, base); got != want {
+			t.Fatalf("AppendInt(%d, %d) = %q, want %q", i, base, got, want)
+		}
+		if got, want := string(AppendUint(nil, uint64(i), base)), strconv.FormatUint(uint64(i), base); got != want {
+			t.Fatalf("AppendUint(%d, %d) = %q, want %q", uint64(i), base, got, want)
+		}
+		if got, want := string(AppendFloat(nil, x, 'g', -1, 64)), strconv.FormatFloat(x, 'g', -1, 64); got != want {
+			t.Fatalf("AppendFloat(%v) = %q, want %q", x, got, want)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package fasttypeconversion
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	i, err := ParseInt([]byte("-42"), 10, 64)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(-42), i)
+	i, err = ParseInt([]byte("0x7f"), 0, 8)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(127), i)
+	u, err := ParseUint([]byte("18446744073709551615"), 10, 64)
+	assert.Empty(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u)
+	f, err := ParseFloat([]byte("3.25e2"), 64)
+	assert.Empty(t, err)
+	assert.Equal(t, 325.0, f)
+	v, err := ParseBool([]byte("T"))
+	assert.Empty(t, err)
+	assert.True(t, v)
+
+	// 错误语义与strconv一致, 且错误信息不受之后修改字节数组的影响
+	b := []byte("128")
+	_, err = ParseInt(b, 10, 8)
+	b[0] = '9'
+	var ne *strconv.NumError
+	assert.True(t, errors.As(err, &ne))
+	assert.Equal(t, "ParseInt", ne.Func)
+	assert.Equal(t, "128", ne.Num)
+	assert.Equal(t, strconv.ErrRange, ne.Err)
+	_, err = ParseUint([]byte("-1"), 10, 64)
+	assert.Equal(t, strconv.ErrSyntax, err.(*strconv.NumError).Err)
+	_, err = ParseFloat([]byte(""), 64)
+	assert.Equal(t, strconv.ErrSyntax, err.(*strconv.NumError).Err)
+	_, err = ParseBool([]byte("yes"))
+	assert.Equal(t, "ParseBool", err.(*strconv.NumError).Func)
+}
+
+func TestParseCheckMode(t *testing.T) {
+	EnableCheck()
+	defer DisableCheck()
+
+	// 解析后复用缓冲区不是对转换结果的修改
+	buf := []byte("123")
+	i, err := ParseInt(buf, 10, 64)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(123), i)
+	copy(buf, "456")
+	i, err = ParseInt(buf, 10, 64)
+	assert.Empty(t, err)
+	assert.Equal(t, int64(456), i)
+	copy(buf, "1.5")
+	_, err = ParseFloat(buf, 64)
+	assert.Empty(t, err)
+	assert.Empty(t, Verify())
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		ParseUint(buf[:1], 10, 64) // nolint
+	}))
+}
+
+func TestAppend(t *testing.T) {
+	dst := []byte("v=")
+	dst = AppendInt(dst, -255, 16)
+	dst = append(dst, ',')
+	dst = AppendUint(dst, 42, 10)
+	dst = append(dst, ',')
+	dst = AppendFloat(dst, 1.5, 'f', -1, 64)
+	dst = append(dst, ',')
+	dst = AppendBool(dst, false)
+	assert.Equal(t, "v=-ff,42,1.5,false", string(dst))
+}
+
+func TestParseAllocs(t *testing.T) {
+	i, f, b := []byte("1234567"), []byte("-1.5e10"), []byte("true")
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		ParseInt(i, 10, 64)  // nolint
+		ParseUint(i, 10, 64) // nolint
+		ParseFloat(f, 64)    // nolint
+		ParseBool(b)         // nolint
+		buf = AppendInt(buf[:0], 1234567, 10)
+		buf = AppendFloat(buf[:0], 1.5, 'g', -1, 64)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func BenchmarkParseInt(b *testing.B) {
+	msg := []byte("1618033988")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseInt(msg, 10, 64) // nolint
+	}
+}
+
+func BenchmarkStrconvParseInt(b *testing.B) {
+	msg := []byte("1618033988")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		strconv.ParseInt(string(msg), 10, 64) // nolint
+	}
+}
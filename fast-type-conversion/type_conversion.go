@@ -1,27 +1,20 @@
 package fasttypeconversion
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 // String2Bytes 字符串快速转换成字节数组, 新变量共享底层数据指针.
+// 返回的字节数组不可修改, 否则会破坏字符串的不可变性, 对字面量字符串还会导致程序崩溃; 可以通过EnableCheck检测此类修改.
 func String2Bytes(s string) []byte {
-	stringHeader := (*reflect.StringHeader)(unsafe.Pointer(&s))
-	bh := reflect.SliceHeader{
-		Data: stringHeader.Data,
-		Len:  stringHeader.Len,
-		Cap:  stringHeader.Len,
+	b := string2Bytes(s)
+	if checkEnabled() {
+		track(b, "String2Bytes")
 	}
-	return *(*[]byte)(unsafe.Pointer(&bh))
+	return b
 }
 
 // Bytes2String 字节数组快速转换成字符串, 新变量共享底层数据指针.
+// 转换后不可再修改原字节数组, 否则字符串的内容会随之改变; 可以通过EnableCheck检测此类修改.
 func Bytes2String(b []byte) string {
-	sliceHeader := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	sh := reflect.StringHeader{
-		Data: sliceHeader.Data,
-		Len:  sliceHeader.Len,
+	if checkEnabled() {
+		track(b, "Bytes2String")
 	}
-	return *(*string)(unsafe.Pointer(&sh))
+	return bytes2String(b)
 }
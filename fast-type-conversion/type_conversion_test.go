@@ -1,6 +1,12 @@
 package fasttypeconversion
 
-import "testing"
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 const N = 1e8 // 10000w
 
@@ -25,3 +31,64 @@ func BenchmarkFastString2Bytes(b *testing.B) {
 		_ = b[0]
 	}
 }
+
+func TestConversion(t *testing.T) {
+	s := "Hello World"
+	b := String2Bytes(s)
+	assert.Equal(t, []byte(s), b)
+	assert.Equal(t, len(s), cap(b))
+	assert.Equal(t, s, Bytes2String(b))
+	assert.Empty(t, String2Bytes(""))
+	assert.Equal(t, "", Bytes2String(nil))
+	assert.Equal(t, "", Bytes2String([]byte{}))
+
+	// 转换后共享底层数据
+	buf := []byte("abc")
+	str := Bytes2String(buf)
+	buf[0] = 'x'
+	assert.Equal(t, "xbc", str)
+}
+
+func TestCheckMutation(t *testing.T) {
+	EnableCheck()
+	defer DisableCheck()
+
+	buf := []byte("feature")
+	_ = Bytes2String(buf)
+	b := String2Bytes(string([]byte("heap string")))
+	assert.Empty(t, Verify())
+
+	buf[0] = 'F'
+	err := Verify()
+	assert.IsType(t, &MutationError{}, err)
+	assert.Equal(t, "Bytes2String", err.(*MutationError).Op)
+	assert.Contains(t, err.(*MutationError).Site, "type_conversion_test.go")
+	// 已报告的修改不会被重复报告
+	assert.Empty(t, Verify())
+
+	b[0] = 'H'
+	err = Verify()
+	assert.Equal(t, "String2Bytes", err.(*MutationError).Op)
+
+	DisableCheck()
+	buf[0] = 'f'
+	assert.Empty(t, Verify())
+}
+
+// TestCheckptr 在开启-d=checkptr的情况下重新运行本包的测试, 校验转换不违反指针规则.
+func TestCheckptr(t *testing.T) {
+	if os.Getenv("FASTCONV_CHECKPTR") != "" {
+		return
+	}
+	if testing.Short() {
+		t.Skip("skipping checkptr run in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command is not available")
+	}
+	cmd := exec.Command(gobin, "test", "-count=1", "-gcflags=-d=checkptr", "-run", "TestConversion|TestCheckMutation|TestViews", ".")
+	cmd.Env = append(os.Environ(), "FASTCONV_CHECKPTR=1")
+	out, err := cmd.CombinedOutput()
+	assert.Empty(t, err, string(out))
+}
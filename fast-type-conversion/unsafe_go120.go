@@ -0,0 +1,14 @@
+//go:build go1.20
+// +build go1.20
+
+package fasttypeconversion
+
+import "unsafe"
+
+func string2Bytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+func bytes2String(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -0,0 +1,24 @@
+//go:build !go1.20
+// +build !go1.20
+
+package fasttypeconversion
+
+import "unsafe"
+
+func string2Bytes(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	sh := (*stringHeader)(unsafe.Pointer(&s))
+	bh := sliceHeader{data: sh.data, len: sh.len, cap: sh.len}
+	return *(*[]byte)(unsafe.Pointer(&bh))
+}
+
+func bytes2String(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	bh := (*sliceHeader)(unsafe.Pointer(&b))
+	sh := stringHeader{data: bh.data, len: bh.len}
+	return *(*string)(unsafe.Pointer(&sh))
+}
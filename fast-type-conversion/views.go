@@ -0,0 +1,194 @@
+package fasttypeconversion
+
+import (
+	"errors"
+	"unsafe"
+)
+
+var (
+	// ErrUnaligned 字节数组的起始地址未按元素类型对齐
+	ErrUnaligned = errors.New("fasttypeconversion: byte slice is not aligned for element type")
+	// ErrLength 字节数组的长度不是元素大小的整数倍
+	ErrLength = errors.New("fasttypeconversion: byte slice length is not a multiple of element size")
+	// ErrByteOrder 本机不是小端字节序, 无法零拷贝地解释小端编码的数据
+	ErrByteOrder = errors.New("fasttypeconversion: host byte order is not little endian")
+)
+
+var littleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// IsLittleEndian 判断本机是否为小端字节序.
+func IsLittleEndian() bool {
+	return littleEndian
+}
+
+// 以下视图函数要求数据按小端字节序编码 (与encoding/binary.LittleEndian一致),
+// 返回的切片与原切片共享底层数据, 修改任意一方都会影响另一方. 空切片转换为nil.
+
+// Int16sFromBytes 将b零拷贝地解释为[]int16.
+func Int16sFromBytes(b []byte) ([]int16, error) {
+	if err := checkView(b, unsafe.Sizeof(int16(0)), unsafe.Alignof(int16(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return int16sOf(b), nil
+}
+
+// Uint16sFromBytes 将b零拷贝地解释为[]uint16.
+func Uint16sFromBytes(b []byte) ([]uint16, error) {
+	if err := checkView(b, unsafe.Sizeof(uint16(0)), unsafe.Alignof(uint16(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return uint16sOf(b), nil
+}
+
+// Int32sFromBytes 将b零拷贝地解释为[]int32.
+func Int32sFromBytes(b []byte) ([]int32, error) {
+	if err := checkView(b, unsafe.Sizeof(int32(0)), unsafe.Alignof(int32(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return int32sOf(b), nil
+}
+
+// Uint32sFromBytes 将b零拷贝地解释为[]uint32.
+func Uint32sFromBytes(b []byte) ([]uint32, error) {
+	if err := checkView(b, unsafe.Sizeof(uint32(0)), unsafe.Alignof(uint32(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return uint32sOf(b), nil
+}
+
+// Int64sFromBytes 将b零拷贝地解释为[]int64.
+func Int64sFromBytes(b []byte) ([]int64, error) {
+	if err := checkView(b, unsafe.Sizeof(int64(0)), unsafe.Alignof(int64(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return int64sOf(b), nil
+}
+
+// Uint64sFromBytes 将b零拷贝地解释为[]uint64.
+func Uint64sFromBytes(b []byte) ([]uint64, error) {
+	if err := checkView(b, unsafe.Sizeof(uint64(0)), unsafe.Alignof(uint64(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return uint64sOf(b), nil
+}
+
+// Float32sFromBytes 将b零拷贝地解释为[]float32.
+func Float32sFromBytes(b []byte) ([]float32, error) {
+	if err := checkView(b, unsafe.Sizeof(float32(0)), unsafe.Alignof(float32(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return float32sOf(b), nil
+}
+
+// Float64sFromBytes 将b零拷贝地解释为[]float64.
+func Float64sFromBytes(b []byte) ([]float64, error) {
+	if err := checkView(b, unsafe.Sizeof(float64(0)), unsafe.Alignof(float64(0))); err != nil || len(b) == 0 {
+		return nil, err
+	}
+	return float64sOf(b), nil
+}
+
+// BytesFromInt16s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromInt16s(v []int16) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfInt16s(v), nil
+}
+
+// BytesFromUint16s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromUint16s(v []uint16) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfUint16s(v), nil
+}
+
+// BytesFromInt32s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromInt32s(v []int32) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfInt32s(v), nil
+}
+
+// BytesFromUint32s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromUint32s(v []uint32) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfUint32s(v), nil
+}
+
+// BytesFromInt64s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromInt64s(v []int64) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfInt64s(v), nil
+}
+
+// BytesFromUint64s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromUint64s(v []uint64) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfUint64s(v), nil
+}
+
+// BytesFromFloat32s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromFloat32s(v []float32) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfFloat32s(v), nil
+}
+
+// BytesFromFloat64s 将v零拷贝地解释为小端编码的字节数组.
+func BytesFromFloat64s(v []float64) ([]byte, error) {
+	if !littleEndian || len(v) == 0 {
+		return nil, checkByteOrder()
+	}
+	return bytesOfFloat64s(v), nil
+}
+
+// Aligned 在b的起始地址按align对齐时原样返回b, 否则返回对齐的拷贝.
+// 用于处理从序列化记录中间切出的数据, 如bigmemcache中Feature的Blob.
+func Aligned(b []byte, align int) []byte {
+	if len(b) == 0 || uintptr(unsafe.Pointer(&b[0]))%uintptr(align) == 0 {
+		return b
+	}
+	// 多分配align-1字节, 从中找到对齐的起始位置
+	buf := make([]byte, len(b)+align-1)
+	off := 0
+	if r := int(uintptr(unsafe.Pointer(&buf[0])) % uintptr(align)); r != 0 {
+		off = align - r
+	}
+	buf = buf[off : off+len(b) : off+len(b)]
+	copy(buf, b)
+	return buf
+}
+
+// checkView 校验b能否被解释为元素大小为size、按align对齐的切片.
+func checkView(b []byte, size, align uintptr) error {
+	if err := checkByteOrder(); err != nil {
+		return err
+	}
+	if uintptr(len(b))%size != 0 {
+		return ErrLength
+	}
+	if len(b) != 0 && uintptr(unsafe.Pointer(&b[0]))%align != 0 {
+		return ErrUnaligned
+	}
+	return nil
+}
+
+func checkByteOrder() error {
+	if !littleEndian {
+		return ErrByteOrder
+	}
+	return nil
+}
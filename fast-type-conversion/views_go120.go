@@ -0,0 +1,70 @@
+//go:build go1.20
+// +build go1.20
+
+package fasttypeconversion
+
+import "unsafe"
+
+func int16sOf(b []byte) []int16 {
+	return unsafe.Slice((*int16)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(int16(0))))
+}
+
+func bytesOfInt16s(v []int16) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(int16(0))))
+}
+
+func uint16sOf(b []byte) []uint16 {
+	return unsafe.Slice((*uint16)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(uint16(0))))
+}
+
+func bytesOfUint16s(v []uint16) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(uint16(0))))
+}
+
+func int32sOf(b []byte) []int32 {
+	return unsafe.Slice((*int32)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(int32(0))))
+}
+
+func bytesOfInt32s(v []int32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(int32(0))))
+}
+
+func uint32sOf(b []byte) []uint32 {
+	return unsafe.Slice((*uint32)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(uint32(0))))
+}
+
+func bytesOfUint32s(v []uint32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(uint32(0))))
+}
+
+func int64sOf(b []byte) []int64 {
+	return unsafe.Slice((*int64)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(int64(0))))
+}
+
+func bytesOfInt64s(v []int64) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(int64(0))))
+}
+
+func uint64sOf(b []byte) []uint64 {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(uint64(0))))
+}
+
+func bytesOfUint64s(v []uint64) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(uint64(0))))
+}
+
+func float32sOf(b []byte) []float32 {
+	return unsafe.Slice((*float32)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(float32(0))))
+}
+
+func bytesOfFloat32s(v []float32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(float32(0))))
+}
+
+func float64sOf(b []byte) []float64 {
+	return unsafe.Slice((*float64)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/int(unsafe.Sizeof(float64(0))))
+}
+
+func bytesOfFloat64s(v []float64) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(v))), len(v)*int(unsafe.Sizeof(float64(0))))
+}
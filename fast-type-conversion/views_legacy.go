@@ -0,0 +1,99 @@
+//go:build !go1.20
+// +build !go1.20
+
+package fasttypeconversion
+
+import "unsafe"
+
+func int16sOf(b []byte) (v []int16) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(int16(0)))
+	return v
+}
+
+func bytesOfInt16s(v []int16) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(int16(0)))
+}
+
+func uint16sOf(b []byte) (v []uint16) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(uint16(0)))
+	return v
+}
+
+func bytesOfUint16s(v []uint16) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(uint16(0)))
+}
+
+func int32sOf(b []byte) (v []int32) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(int32(0)))
+	return v
+}
+
+func bytesOfInt32s(v []int32) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(int32(0)))
+}
+
+func uint32sOf(b []byte) (v []uint32) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(uint32(0)))
+	return v
+}
+
+func bytesOfUint32s(v []uint32) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(uint32(0)))
+}
+
+func int64sOf(b []byte) (v []int64) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(int64(0)))
+	return v
+}
+
+func bytesOfInt64s(v []int64) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(int64(0)))
+}
+
+func uint64sOf(b []byte) (v []uint64) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(uint64(0)))
+	return v
+}
+
+func bytesOfUint64s(v []uint64) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(uint64(0)))
+}
+
+func float32sOf(b []byte) (v []float32) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(float32(0)))
+	return v
+}
+
+func bytesOfFloat32s(v []float32) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(float32(0)))
+}
+
+func float64sOf(b []byte) (v []float64) {
+	viewOf(unsafe.Pointer(&v), b, unsafe.Sizeof(float64(0)))
+	return v
+}
+
+func bytesOfFloat64s(v []float64) []byte {
+	return bytesOf(unsafe.Pointer(&v), unsafe.Sizeof(float64(0)))
+}
+
+// viewOf 将b解释为元素大小为size的切片, 写入dst指向的切片变量.
+func viewOf(dst unsafe.Pointer, b []byte, size uintptr) {
+	*(*sliceHeader)(dst) = sliceHeader{
+		data: unsafe.Pointer(&b[0]),
+		len:  len(b) / int(size),
+		cap:  len(b) / int(size),
+	}
+}
+
+// bytesOf 将src指向的元素大小为size的切片解释为字节数组.
+func bytesOf(src unsafe.Pointer, size uintptr) []byte {
+	h := (*sliceHeader)(src)
+	var b []byte
+	*(*sliceHeader)(unsafe.Pointer(&b)) = sliceHeader{
+		data: h.data,
+		len:  h.len * int(size),
+		cap:  h.len * int(size),
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package fasttypeconversion
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViews(t *testing.T) {
+	if !IsLittleEndian() {
+		_, err := Float32sFromBytes(make([]byte, 8))
+		assert.Equal(t, ErrByteOrder, err)
+		t.Skip("host is big endian")
+	}
+
+	vec := []float32{0.5, -1.25, 3, float32(math.Inf(1))}
+	raw := make([]byte, 4*len(vec)+1)
+	for i, f := range vec {
+		binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(f))
+	}
+	got, err := Float32sFromBytes(raw[:16])
+	assert.Empty(t, err)
+	assert.Equal(t, vec, got)
+	// 共享底层数据
+	got[0] = 2
+	assert.Equal(t, math.Float32bits(2), binary.LittleEndian.Uint32(raw))
+
+	_, err = Float32sFromBytes(raw[:15])
+	assert.Equal(t, ErrLength, err)
+	_, err = Float32sFromBytes(raw[1:])
+	assert.Equal(t, ErrUnaligned, err)
+	aligned := Aligned(raw[1:], 4)
+	assert.Equal(t, raw[1:], aligned)
+	_, err = Float32sFromBytes(aligned)
+	assert.Empty(t, err)
+	assert.Equal(t, raw[:16], Aligned(raw[:16], 4))
+
+	ids := []int64{1, -2, math.MaxInt64}
+	b, err := BytesFromInt64s(ids)
+	assert.Empty(t, err)
+	assert.Len(t, b, 24)
+	assert.Equal(t, uint64(math.MaxInt64), binary.LittleEndian.Uint64(b[16:]))
+	back, err := Int64sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, ids, back)
+
+	u16, err := Uint16sFromBytes([]byte{1, 0, 0, 1})
+	assert.Empty(t, err)
+	assert.Equal(t, []uint16{1, 256}, u16)
+
+	empty, err := Float64sFromBytes(nil)
+	assert.Empty(t, err)
+	assert.Nil(t, empty)
+	eb, err := BytesFromFloat64s([]float64{})
+	assert.Empty(t, err)
+	assert.Nil(t, eb)
+}
+
+func TestViewsRoundTrip(t *testing.T) {
+	f64 := []float64{math.Pi, -0.0, 1e300}
+	b, _ := BytesFromFloat64s(f64)
+	v, err := Float64sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, f64, v)
+
+	i32 := []int32{-1, 7}
+	b, _ = BytesFromInt32s(i32)
+	iv, err := Int32sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, i32, iv)
+
+	u32 := []uint32{math.MaxUint32}
+	b, _ = BytesFromUint32s(u32)
+	uv, err := Uint32sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, u32, uv)
+
+	u64 := []uint64{math.MaxUint64}
+	b, _ = BytesFromUint64s(u64)
+	u64v, err := Uint64sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, u64, u64v)
+
+	i16 := []int16{-3}
+	b, _ = BytesFromInt16s(i16)
+	i16v, err := Int16sFromBytes(b)
+	assert.Empty(t, err)
+	assert.Equal(t, i16, i16v)
+
+	f32 := []float32{1.5}
+	b, _ = BytesFromFloat32s(f32)
+	assert.Equal(t, math.Float32bits(1.5), binary.LittleEndian.Uint32(b))
+	b, _ = BytesFromUint16s([]uint16{0x0102})
+	assert.Equal(t, []byte{2, 1}, b)
+}
@@ -1,21 +1,45 @@
 package fwriter
 
 import (
+	"context"
 	"errors"
 	"os"
+	"sync"
 	"syscall"
+	"time"
 )
 
-// FLock 文件锁
+var (
+	// ErrLocked 文件锁已被其他进程或线程持有
+	ErrLocked = errors.New("file has been locked by another thread")
+	// ErrHeld 当前FLock对象已经持有文件锁
+	ErrHeld = errors.New("file lock is already held")
+	// ErrNotHeld 当前FLock对象没有持有文件锁
+	ErrNotHeld = errors.New("file lock is not held")
+)
+
+// 阻塞获取文件锁时的轮询间隔
+const (
+	__MinLockRetryInterval = time.Millisecond
+	__MaxLockRetryInterval = 100 * time.Millisecond
+)
+
+// FLock 文件锁 (线程安全), 基于flock(2), 进程退出时由内核自动释放.
+// 锁文件在删除前必须持有互斥锁, 获取锁后会确认锁文件未被替换, 因此删除锁文件不会破坏互斥性.
 type FLock struct {
 	fn string
-	fd int
+
+	mu        sync.Mutex
+	fd        int
+	held      bool
+	exclusive bool
 }
 
 // NewFLock 新建FLock对象.
 func NewFLock(fn string) *FLock {
 	return &FLock{
 		fn: fn + ".lock",
+		fd: -1,
 	}
 }
 
@@ -24,39 +48,130 @@ func (l *FLock) File() string {
 	return l.fn
 }
 
-// Acquire 获取文件锁.
+// Acquire 获取互斥锁(非阻塞), 锁被其他进程或线程持有时返回ErrLocked.
 func (l *FLock) Acquire() error {
-	if err := l.open(); err != nil {
-		return err
+	return l.tryLock(syscall.LOCK_EX)
+}
+
+// AcquireShared 获取共享锁(非阻塞), 多个读者可以同时持有共享锁, 互斥锁被持有时返回ErrLocked.
+func (l *FLock) AcquireShared() error {
+	return l.tryLock(syscall.LOCK_SH)
+}
+
+// AcquireContext 阻塞地获取互斥锁, ctx被取消时放弃等待并返回ctx.Err().
+func (l *FLock) AcquireContext(ctx context.Context) error {
+	return l.lockContext(ctx, syscall.LOCK_EX)
+}
+
+// AcquireSharedContext 阻塞地获取共享锁, ctx被取消时放弃等待并返回ctx.Err().
+func (l *FLock) AcquireSharedContext(ctx context.Context) error {
+	return l.lockContext(ctx, syscall.LOCK_SH)
+}
+
+// lockContext 以逐渐增大的间隔轮询, flock(2)的阻塞调用无法被取消.
+func (l *FLock) lockContext(ctx context.Context, how int) error {
+	interval := __MinLockRetryInterval
+	for {
+		err := l.tryLock(how)
+		if err != ErrLocked {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > __MaxLockRetryInterval {
+			interval = __MaxLockRetryInterval
+		}
 	}
-	// 非阻塞互斥锁
-	if err := syscall.Flock(l.fd, syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
-		// 需要关闭由多余的Flock操作打开的文件句柄
-		if _err := syscall.Close(l.fd); _err != nil {
-			return _err
+}
+
+func (l *FLock) tryLock(how int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held {
+		return ErrHeld
+	}
+	for {
+		fd, err := syscall.Open(l.fn, syscall.O_CREAT|syscall.O_RDONLY|syscall.O_CLOEXEC, 0600)
+		if err != nil {
+			return &os.PathError{Op: "open", Path: l.fn, Err: err}
+		}
+		// 非阻塞加锁
+		if err = syscall.Flock(fd, how|syscall.LOCK_NB); err != nil {
+			// 需要关闭由多余的Flock操作打开的文件句柄
+			syscall.Close(fd) // nolint
+			if err == syscall.EWOULDBLOCK {
+				return ErrLocked
+			}
+			return &os.PathError{Op: "flock", Path: l.fn, Err: err}
+		}
+		// 加锁期间锁文件可能已被持有者删除, 此时锁住的是孤立的inode, 需要重新打开
+		same, err := l.sameFile(fd)
+		if err != nil {
+			syscall.Close(fd) // nolint
+			return err
 		}
-		if err == syscall.EWOULDBLOCK {
-			return errors.New("file has been locked by another thread")
+		if !same {
+			syscall.Close(fd) // nolint
+			continue
 		}
+		l.fd, l.held, l.exclusive = fd, true, how == syscall.LOCK_EX
+		return nil
 	}
-	return nil
 }
 
-func (l *FLock) open() error {
-	fd, err := syscall.Open(l.fn, syscall.O_CREAT|syscall.O_RDONLY, 0600)
-	if err != nil {
-		return err
+// sameFile 判断fd是否仍然对应路径l.fn上的文件.
+func (l *FLock) sameFile(fd int) (bool, error) {
+	var fst, pst syscall.Stat_t
+	if err := syscall.Fstat(fd, &fst); err != nil {
+		return false, &os.PathError{Op: "fstat", Path: l.fn, Err: err}
 	}
-	l.fd = fd
-	return nil
+	if err := syscall.Stat(l.fn, &pst); err != nil {
+		if err == syscall.ENOENT {
+			return false, nil
+		}
+		return false, &os.PathError{Op: "stat", Path: l.fn, Err: err}
+	}
+	return fst.Dev == pst.Dev && fst.Ino == pst.Ino, nil
 }
 
-// Release 释放文件锁.
+// Release 释放文件锁, 锁文件会被保留.
 func (l *FLock) Release() error {
-	return syscall.Close(l.fd)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.release()
 }
 
-// Remove 删除文件锁保护的文件.
+func (l *FLock) release() error {
+	if !l.held {
+		return ErrNotHeld
+	}
+	fd := l.fd
+	l.fd, l.held, l.exclusive = -1, false, false
+	err := syscall.Flock(fd, syscall.LOCK_UN)
+	// 无论解锁是否成功都要关闭文件句柄, 关闭时内核同样会释放锁
+	if cerr := syscall.Close(fd); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return &os.PathError{Op: "unlock", Path: l.fn, Err: err}
+	}
+	return nil
+}
+
+// Remove 删除锁文件并释放文件锁, 必须在持有互斥锁时调用.
+// 删除发生在释放锁之前, 正在等待的进程获取锁后会发现锁文件已被删除, 并重新创建锁文件.
 func (l *FLock) Remove() error {
-	return os.Remove(l.fn)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held || !l.exclusive {
+		return ErrNotHeld
+	}
+	err := os.Remove(l.fn)
+	if rerr := l.release(); err == nil {
+		err = rerr
+	}
+	return err
 }
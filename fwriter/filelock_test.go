@@ -0,0 +1,132 @@
+package fwriter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelperProcess 不是真正的测试, 而是被重新执行的测试二进制中持有文件锁的子进程.
+// 子进程获取锁后输出"locked", 在标准输入关闭后退出.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("FWRITER_HELPER_MODE")
+	if mode == "" {
+		return
+	}
+	l := NewFLock(os.Getenv("FWRITER_HELPER_FILE"))
+	var err error
+	if mode == "shared" {
+		err = l.AcquireShared()
+	} else {
+		err = l.Acquire()
+	}
+	if err != nil {
+		os.Stdout.WriteString(err.Error() + "\n") // nolint
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("locked\n") // nolint
+	io.Copy(ioutil.Discard, os.Stdin) // nolint
+	os.Exit(0)
+}
+
+// lockProcess 启动以mode模式持有fn文件锁的子进程, 关闭返回的写入端后子进程退出.
+func lockProcess(t *testing.T, fn, mode string) (io.WriteCloser, *exec.Cmd) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "FWRITER_HELPER_MODE="+mode, "FWRITER_HELPER_FILE="+fn)
+	stdin, err := cmd.StdinPipe()
+	assert.Empty(t, err)
+	stdout, err := cmd.StdoutPipe()
+	assert.Empty(t, err)
+	assert.Empty(t, cmd.Start())
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	assert.Empty(t, err)
+	assert.Equal(t, "locked\n", line)
+	return stdin, cmd
+}
+
+func TestFLockMultiProcess(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "data")
+	stdin, cmd := lockProcess(t, fn, "exclusive")
+
+	l := NewFLock(fn)
+	assert.Equal(t, ErrLocked, l.Acquire())
+	assert.Equal(t, ErrLocked, l.AcquireShared())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, l.AcquireContext(ctx))
+
+	// 子进程退出后内核释放锁, 阻塞等待的调用随之成功
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		stdin.Close()
+	}()
+	assert.Empty(t, l.AcquireContext(context.Background()))
+	assert.Empty(t, cmd.Wait())
+	assert.Equal(t, ErrHeld, l.Acquire())
+	assert.Empty(t, l.Release())
+	assert.Equal(t, ErrNotHeld, l.Release())
+}
+
+func TestFLockShared(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "data")
+	stdin, cmd := lockProcess(t, fn, "shared")
+
+	reader := NewFLock(fn)
+	assert.Empty(t, reader.AcquireShared())
+	writer := NewFLock(fn)
+	assert.Equal(t, ErrLocked, writer.Acquire())
+	// 共享锁不能删除锁文件
+	assert.Equal(t, ErrNotHeld, reader.Remove())
+	assert.Empty(t, reader.Release())
+	assert.Equal(t, ErrLocked, writer.Acquire())
+
+	stdin.Close()
+	assert.Empty(t, cmd.Wait())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Empty(t, writer.AcquireContext(ctx))
+	assert.Equal(t, ErrLocked, reader.AcquireShared())
+	assert.Empty(t, writer.Release())
+}
+
+func TestFLockRemove(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "data")
+	holder := NewFLock(fn)
+	assert.Empty(t, holder.Acquire())
+
+	// 等待者在持有者删除锁文件并释放锁后, 锁住的是新创建的锁文件
+	waiter := NewFLock(fn)
+	done := make(chan error)
+	go func() {
+		done <- waiter.AcquireContext(context.Background())
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, holder.Remove())
+	assert.Empty(t, <-done)
+	_, err := os.Stat(holder.File())
+	assert.Empty(t, err)
+
+	// 锁住的是重新创建的锁文件, 其他进程同样无法获取锁
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "FWRITER_HELPER_MODE=shared", "FWRITER_HELPER_FILE="+fn)
+	out, err := cmd.Output()
+	assert.NotEmpty(t, err)
+	assert.Equal(t, ErrLocked.Error()+"\n", string(out))
+	assert.Empty(t, waiter.Remove())
+}
+
+func TestFLockOpenError(t *testing.T) {
+	l := NewFLock(filepath.Join(t.TempDir(), "missing", "data"))
+	err := l.Acquire()
+	assert.True(t, os.IsNotExist(err))
+	// 打开失败后没有残留的持有状态
+	assert.Equal(t, ErrNotHeld, l.Release())
+}
@@ -4,19 +4,36 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
 	"time"
 )
 
+// Options SafeWriter配置
+type Options struct {
+	PreserveMode  bool // 提交时沿用原文件的权限位
+	PreserveOwner bool // 提交时沿用原文件的uid和gid, 通常需要root权限
+}
+
 // SafeWriter 文件读写器 (线程安全).
 type SafeWriter struct {
 	flock     *FLock
 	writer    *os.File
 	fn        string
 	tmpSuffix string
+	opts      Options
+	err       error // 首个写错误, 出错后的提交一律失败
 }
 
 // NewSafeWriter 新建SafeWriter对象.
 func NewSafeWriter(fn string) (*SafeWriter, error) {
+	return NewSafeWriterWithOptions(fn, nil)
+}
+
+// NewSafeWriterWithOptions 按opts新建SafeWriter对象, opts为nil时使用默认配置.
+func NewSafeWriterWithOptions(fn string, opts *Options) (*SafeWriter, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
 	if err := os.MkdirAll(filepath.Dir(fn), 0750); err != nil {
 		return nil, err
 	}
@@ -30,8 +47,7 @@ func NewSafeWriter(fn string) (*SafeWriter, error) {
 
 	writer, err := os.OpenFile(fn+tmpSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		flock.Release() // nolint
-		flock.Remove()  // nolint
+		flock.Remove() // nolint
 		return nil, err
 	}
 
@@ -40,29 +56,53 @@ func NewSafeWriter(fn string) (*SafeWriter, error) {
 		writer:    writer,
 		fn:        fn,
 		tmpSuffix: tmpSuffix,
+		opts:      *opts,
 	}, nil
 }
 
 // Write 写字节流.
 func (w *SafeWriter) Write(content []byte) (int, error) {
-	return w.writer.Write(content)
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.Write(content)
+	w.err = err
+	return n, err
 }
 
 // WriteString 写字符串.
 func (w *SafeWriter) WriteString(content string) (int, error) {
-	return w.writer.WriteString(content)
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.WriteString(content)
+	w.err = err
+	return n, err
 }
 
 // Commit 持久化内存数据到硬盘.
+// 临时文件和父目录都落盘后才返回nil, 掉电后不会丢失本次替换.
+// 写入、刷盘失败 (如磁盘已满) 时删除临时文件并保持原文件不变;
+// 仅在改名成功而父目录刷盘失败时, 原文件已被替换但返回错误.
 func (w *SafeWriter) Commit() error {
 	defer w.exit()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.preserve(); err != nil {
+		return err
+	}
 	if err := w.writer.Sync(); err != nil {
 		return err
 	}
+	// 部分文件系统 (如NFS) 直到关闭时才报告写错误
+	if err := w.writer.Close(); err != nil {
+		return err
+	}
 	if err := os.Rename(w.fn+w.tmpSuffix, w.fn); err != nil {
 		return err
 	}
-	return nil
+	return syncDir(filepath.Dir(w.fn))
 }
 
 // Abort 放弃当前写操作.
@@ -70,6 +110,33 @@ func (w *SafeWriter) Abort() {
 	w.exit()
 }
 
+// preserve 按配置将原文件的属主和权限位复制到临时文件, 原文件不存在时不做处理.
+func (w *SafeWriter) preserve() error {
+	if !w.opts.PreserveMode && !w.opts.PreserveOwner {
+		return nil
+	}
+	fi, err := os.Stat(w.fn)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// 修改属主会清除setuid/setgid位, 因此先改属主再改权限
+	if w.opts.PreserveOwner {
+		st := fi.Sys().(*syscall.Stat_t)
+		if err = w.writer.Chown(int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	if w.opts.PreserveMode {
+		mode := fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err = w.writer.Chmod(mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *SafeWriter) exit() {
 	w.writer.Close() // nolint
 	w.unlock()
@@ -77,6 +144,27 @@ func (w *SafeWriter) exit() {
 }
 
 func (w *SafeWriter) unlock() {
-	w.flock.Release() // nolint
-	w.flock.Remove()  // nolint
+	// 持有锁时删除锁文件, 删除会同时释放锁
+	w.flock.Remove() // nolint
+}
+
+// syncDir 刷盘目录项, 使目录下的创建、改名和删除操作持久化.
+func syncDir(dir string) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	// 部分文件系统不支持目录fsync, 返回EINVAL
+	if err = fd.Sync(); err != nil && !isErrno(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == errno
 }
@@ -0,0 +1,30 @@
+package fwriter
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeWriterDiskFull(t *testing.T) {
+	dir := t.TempDir()
+	if err := syscall.Mount("tmpfs", dir, "tmpfs", 0, "size=64k"); err != nil {
+		t.Skipf("tmpfs is not available: %v", err)
+	}
+	defer syscall.Unmount(dir, 0) // nolint
+	fn := filepath.Join(dir, "test.txt")
+	assert.Empty(t, ioutil.WriteFile(fn, []byte("old"), 0644))
+
+	w, err := NewSafeWriter(fn)
+	assert.Empty(t, err)
+	chunk := make([]byte, 4096)
+	for i := 0; i < 64 && err == nil; i++ {
+		_, err = w.Write(chunk)
+	}
+	assert.True(t, isErrno(err, syscall.ENOSPC))
+	assert.True(t, isErrno(w.Commit(), syscall.ENOSPC))
+	assertIntact(t, fn, "old")
+}
@@ -1,7 +1,10 @@
 package fwriter
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,3 +24,51 @@ func TestSafeWriter(t *testing.T) {
 	err = os.Remove("./fixtures/test.txt")
 	assert.Empty(t, err)
 }
+
+func TestSafeWriterPreserve(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.txt")
+	assert.Empty(t, ioutil.WriteFile(fn, []byte("old"), 0600))
+	assert.Empty(t, os.Chmod(fn, 0600))
+
+	w, err := NewSafeWriterWithOptions(fn, &Options{PreserveMode: true, PreserveOwner: true})
+	assert.Empty(t, err)
+	_, err = w.WriteString("new")
+	assert.Empty(t, err)
+	assert.Empty(t, w.Commit())
+	fi, err := os.Stat(fn)
+	assert.Empty(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	st := fi.Sys().(*syscall.Stat_t)
+	assert.Equal(t, os.Getuid(), int(st.Uid))
+
+	// 默认不保留原文件的权限位
+	w, err = NewSafeWriter(fn)
+	assert.Empty(t, err)
+	assert.Empty(t, w.Commit())
+	fi, err = os.Stat(fn)
+	assert.Empty(t, err)
+	assert.NotEqual(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestSafeWriterWriteError(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.txt")
+	assert.Empty(t, ioutil.WriteFile(fn, []byte("old"), 0644))
+
+	w, err := NewSafeWriter(fn)
+	assert.Empty(t, err)
+	w.writer.Close() // nolint
+	_, err = w.WriteString("new")
+	assert.NotEmpty(t, err)
+	assert.Equal(t, err, w.Commit())
+	assertIntact(t, fn, "old")
+}
+
+// assertIntact 校验提交失败后原文件未被改动, 且临时文件和锁文件均已清理.
+func assertIntact(t *testing.T, fn, content string) {
+	b, err := ioutil.ReadFile(fn)
+	assert.Empty(t, err)
+	assert.Equal(t, content, string(b))
+	entries, err := ioutil.ReadDir(filepath.Dir(fn))
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(entries))
+}
@@ -0,0 +1,472 @@
+package fwriter
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amazingchow/photon-dance-golang-snippets/compress"
+)
+
+// ErrClosedWriter 写入器已关闭
+var ErrClosedWriter = errors.New("writer has been closed")
+
+// 历史文件名中的时间戳格式, 定长以便按文件名排序
+const __BackupTimeFormat = "20060102T150405.000000000"
+
+// RotateCfg RotateWriter配置
+type RotateCfg struct {
+	MaxSize      int64          // 单个日志文件的字节数上限, 不大于0时不按大小切分
+	MaxAge       time.Duration  // 单个日志文件自打开或上次切分起的写入时长上限, 不大于0时不按时间切分
+	MaxBackups   int            // 保留的历史文件数量, 不大于0时全部保留
+	Compress     compress.Codec // 后台压缩历史文件所用的算法, CodecNone表示不压缩
+	SyncInterval time.Duration  // 组提交刷盘的间隔, 不大于0时每次写入后立即刷盘
+}
+
+// RotateWriter 只追加的日志写入器 (线程安全), 实现了io.Writer, 可直接作为zerolog的输出.
+// 日志文件按大小和时长切分为fn.<时间戳>[.压缩后缀]形式的历史文件,
+// 同一个日志文件同时只能被一个RotateWriter打开, 跨进程互斥由FLock保证.
+type RotateWriter struct {
+	mu      sync.Mutex
+	fn      string
+	cfg     RotateCfg
+	flock   *FLock
+	file    *os.File
+	size    int64
+	created time.Time
+	dirty   bool  // 存在尚未刷盘的写入
+	err     error // 后台刷盘或压缩的错误, 在下一次Write或Sync时返回
+	closed  bool
+	now     func() time.Time // 当前时间, 测试中可以替换
+
+	millCh chan struct{}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRotateWriter 打开日志文件fn用于追加写, cfg为nil时使用默认配置 (不切分, 每次写入后刷盘).
+// fn已被其他RotateWriter打开时返回ErrLocked.
+func NewRotateWriter(fn string, cfg *RotateCfg) (*RotateWriter, error) {
+	if cfg == nil {
+		cfg = &RotateCfg{}
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0750); err != nil {
+		return nil, err
+	}
+
+	flock := NewFLock(fn)
+	if err := flock.Acquire(); err != nil {
+		return nil, err
+	}
+
+	w := &RotateWriter{
+		fn:     fn,
+		cfg:    *cfg,
+		flock:  flock,
+		now:    time.Now,
+		millCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+	}
+	if err := w.openFile(); err != nil {
+		flock.Remove() // nolint
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.millLoop()
+	// 处理上次退出前未来得及压缩或清理的历史文件
+	w.mill()
+	if w.cfg.SyncInterval > 0 {
+		w.wg.Add(1)
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+// Write 追加写入p, 单次写入的内容总是落在同一个日志文件中.
+// 写入后需要切分时先切分再写入; p本身超过MaxSize时独占一个日志文件.
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosedWriter
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		return 0, err
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if w.cfg.SyncInterval <= 0 {
+		return n, w.file.Sync()
+	}
+	w.dirty = true
+	return n, nil
+}
+
+// Rotate 立即切分当前日志文件.
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosedWriter
+	}
+	return w.rotate()
+}
+
+// Sync 立即刷盘尚未持久化的写入.
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosedWriter
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		return err
+	}
+	return w.sync()
+}
+
+// Close 刷盘并关闭日志文件, 释放文件锁.
+// 正在进行的后台压缩会先完成, 尚未开始的压缩留到下次打开时进行.
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrClosedWriter
+	}
+	w.closed = true
+	err := w.sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.mu.Unlock()
+
+	close(w.stopCh)
+	w.wg.Wait()
+	w.flock.Remove() // nolint
+	return err
+}
+
+func (w *RotateWriter) openFile() error {
+	file, err := os.OpenFile(w.fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close() // nolint
+		return err
+	}
+	w.file = file
+	w.size = fi.Size()
+	w.created = w.now()
+	return nil
+}
+
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.cfg.MaxSize > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.MaxAge > 0 && w.now().Sub(w.created) >= w.cfg.MaxAge
+}
+
+// rotate 将当前日志文件改名为历史文件并打开新的日志文件, 调用方需持有w.mu.
+func (w *RotateWriter) rotate() error {
+	if err := w.sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	backup := w.backupName(w.now())
+	if err := os.Rename(w.fn, backup); err != nil {
+		// 改名失败时继续写原文件
+		if oerr := w.openFile(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	if err := w.openFile(); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(w.fn)); err != nil {
+		return err
+	}
+	w.mill()
+	return nil
+}
+
+// sync 刷盘尚未持久化的写入, 调用方需持有w.mu.
+func (w *RotateWriter) sync() error {
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// backupName 返回t时刻切分出的历史文件名, 同名文件已存在时顺延1ns.
+func (w *RotateWriter) backupName(t time.Time) string {
+	t = t.UTC()
+	for {
+		name := w.fn + "." + t.Format(__BackupTimeFormat)
+		if !backupExists(name) {
+			return name
+		}
+		t = t.Add(time.Nanosecond)
+	}
+}
+
+// backupExists 判断历史文件name是否已经存在, 包括以任一算法压缩后的文件.
+func backupExists(name string) bool {
+	for _, ext := range append([]string{""}, __CompressedExts...) {
+		if _, err := os.Lstat(name + ext); !os.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncLoop 按SyncInterval组提交刷盘.
+func (w *RotateWriter) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed {
+				if err := w.sync(); err != nil && w.err == nil {
+					w.err = err
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// millLoop 在后台压缩并清理历史文件.
+func (w *RotateWriter) millLoop() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-w.millCh:
+			w.millOnce()
+		}
+	}
+}
+
+// mill 通知后台协程处理历史文件, 已有待处理的通知时直接返回.
+func (w *RotateWriter) mill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+// backupFile 历史文件
+type backupFile struct {
+	name       string
+	ts         time.Time
+	compressed bool
+}
+
+// millOnce 压缩尚未压缩的历史文件, 并删除超出MaxBackups的最旧的历史文件.
+// 失败的压缩保留原文件, 在下一次切分时重试, 错误在下一次Write或Sync时返回.
+func (w *RotateWriter) millOnce() {
+	backups, err := w.listBackups()
+	if err != nil {
+		w.setErr(err)
+		return
+	}
+
+	if w.cfg.MaxBackups > 0 && len(backups) > w.cfg.MaxBackups {
+		for _, b := range backups[w.cfg.MaxBackups:] {
+			os.Remove(b.name) // nolint
+		}
+		backups = backups[:w.cfg.MaxBackups]
+	}
+
+	if w.cfg.Compress != compress.CodecNone {
+		for _, b := range backups {
+			select {
+			case <-w.stopCh:
+				return
+			default:
+			}
+			if !b.compressed {
+				if err = compressFile(b.name, w.cfg.Compress); err != nil {
+					w.setErr(err)
+				}
+			}
+		}
+	}
+}
+
+// setErr 记录后台任务的错误, 已有未返回的错误时保留之前的错误.
+func (w *RotateWriter) setErr(err error) {
+	w.mu.Lock()
+	if !w.closed && w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+// listBackups 按时间从新到旧返回全部历史文件, 以任一算法压缩的历史文件都会被计入,
+// 因此修改Compress后之前压缩的历史文件仍受MaxBackups限制.
+// 同一时间戳同时存在压缩前后两个文件时 (压缩完成但未删除原文件), 删除原文件;
+// 压缩中途退出残留的临时文件也会被删除.
+func (w *RotateWriter) listBackups() ([]backupFile, error) {
+	dir, base := filepath.Split(w.fn)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := base + "."
+	byTime := make(map[time.Time]backupFile)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		b := backupFile{name: filepath.Join(dir, name)}
+		stamp := strings.TrimPrefix(name, prefix)
+		tmp := strings.HasSuffix(stamp, ".tmp")
+		stamp = strings.TrimSuffix(stamp, ".tmp")
+		for _, ext := range __CompressedExts {
+			if strings.HasSuffix(stamp, ext) {
+				stamp = strings.TrimSuffix(stamp, ext)
+				b.compressed = true
+				break
+			}
+		}
+		if b.ts, err = time.Parse(__BackupTimeFormat, stamp); err != nil {
+			// 锁文件等
+			continue
+		}
+		if tmp {
+			// 只有本写入器的后台协程会压缩历史文件, 此时不存在进行中的压缩
+			os.Remove(b.name) // nolint
+			continue
+		}
+		if old, ok := byTime[b.ts]; ok {
+			if old.compressed {
+				os.Remove(b.name) // nolint
+				continue
+			}
+			os.Remove(old.name) // nolint
+		}
+		byTime[b.ts] = b
+	}
+
+	backups := make([]backupFile, 0, len(byTime))
+	for _, b := range byTime {
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ts.After(backups[j].ts)
+	})
+	return backups, nil
+}
+
+// compressFile 将fn压缩为fn加压缩后缀, 压缩结果落盘后再删除fn.
+func compressFile(fn string, codec compress.Codec) (err error) {
+	src, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst := fn + codecExt(codec)
+	tmp, err := os.OpenFile(dst+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()           // nolint
+			os.Remove(tmp.Name()) // nolint
+		}
+	}()
+
+	cw, err := compress.NewWriter(tmp, codec, compress.LevelDefault)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(cw, src); err != nil {
+		return err
+	}
+	if err = cw.Close(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	if err = syncDir(filepath.Dir(fn)); err != nil {
+		return err
+	}
+	return os.Remove(fn)
+}
+
+// __CompressedExts 各压缩算法对应的文件后缀
+var __CompressedExts = []string{".gz", ".zst", ".lz4", ".sz"}
+
+// codecExt 返回压缩算法对应的文件后缀.
+func codecExt(codec compress.Codec) string {
+	switch codec {
+	case compress.CodecGzip:
+		return ".gz"
+	case compress.CodecZstd:
+		return ".zst"
+	case compress.CodecLz4:
+		return ".lz4"
+	case compress.CodecSnappy:
+		return ".sz"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,191 @@
+package fwriter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amazingchow/photon-dance-golang-snippets/compress"
+)
+
+// readLogs 按时间从旧到新返回fn的全部历史文件和当前日志文件的内容.
+func readLogs(t *testing.T, fn string) []string {
+	names, err := filepath.Glob(fn + ".2*")
+	assert.Empty(t, err)
+	sort.Strings(names)
+	names = append(names, fn)
+
+	contents := make([]string, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		assert.Empty(t, err)
+		r, err := compress.NewReader(f)
+		assert.Empty(t, err)
+		b, err := ioutil.ReadAll(r)
+		assert.Empty(t, err)
+		r.Close() // nolint
+		f.Close() // nolint
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+func TestRotateWriterSize(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, &RotateCfg{MaxSize: 8})
+	assert.Empty(t, err)
+	for _, s := range []string{"aaaa", "bbbb", "cccc", "dddddddddd", "e"} {
+		n, err := w.Write([]byte(s))
+		assert.Empty(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Empty(t, w.Close())
+	assert.Equal(t, ErrClosedWriter, w.Close())
+	_, err = w.Write([]byte("f"))
+	assert.Equal(t, ErrClosedWriter, err)
+
+	// 单次写入不会被拆分到两个文件中, 超过MaxSize的写入独占一个文件
+	assert.Equal(t, []string{"aaaabbbb", "cccc", "dddddddddd", "e"}, readLogs(t, fn))
+	_, err = os.Stat(fn + ".lock")
+	assert.True(t, os.IsNotExist(err))
+
+	// 重新打开时在原文件末尾追加
+	w, err = NewRotateWriter(fn, &RotateCfg{MaxSize: 8})
+	assert.Empty(t, err)
+	_, err = w.Write([]byte("ff"))
+	assert.Empty(t, err)
+	assert.Empty(t, w.Close())
+	assert.Equal(t, []string{"aaaabbbb", "cccc", "dddddddddd", "eff"}, readLogs(t, fn))
+}
+
+func TestRotateWriterAge(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, &RotateCfg{MaxAge: time.Minute})
+	assert.Empty(t, err)
+	defer w.Close()
+	now := time.Now()
+	w.mu.Lock()
+	w.now = func() time.Time { return now }
+	w.created = now
+	w.mu.Unlock()
+
+	_, err = w.Write([]byte("a"))
+	assert.Empty(t, err)
+	now = now.Add(time.Minute - time.Nanosecond)
+	_, err = w.Write([]byte("b"))
+	assert.Empty(t, err)
+	now = now.Add(time.Nanosecond)
+	_, err = w.Write([]byte("c"))
+	assert.Empty(t, err)
+	assert.Equal(t, []string{"ab", "c"}, readLogs(t, fn))
+}
+
+func TestRotateWriterBackups(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, &RotateCfg{MaxBackups: 2, Compress: compress.CodecGzip})
+	assert.Empty(t, err)
+	defer w.Close()
+
+	for _, s := range []string{"a", "b", "c", "d"} {
+		_, err = w.Write([]byte(s))
+		assert.Empty(t, err)
+		assert.Empty(t, w.Rotate())
+	}
+	_, err = w.Write([]byte("e"))
+	assert.Empty(t, err)
+
+	// 后台压缩和清理完成后只剩下最新的两个历史文件
+	assert.Eventually(t, func() bool {
+		names, _ := filepath.Glob(fn + ".2*")
+		if len(names) != 2 {
+			return false
+		}
+		for _, name := range names {
+			if !strings.HasSuffix(name, ".gz") {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"c", "d", "e"}, readLogs(t, fn))
+}
+
+func TestRotateWriterStaleBackups(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+	// 以其他算法压缩的历史文件和压缩中途退出残留的临时文件
+	stamp := func(i int) string {
+		return time.Date(2021, 1, 1, 0, 0, i, 0, time.UTC).Format(__BackupTimeFormat)
+	}
+	for _, name := range []string{stamp(1) + ".zst", stamp(2) + ".sz", stamp(3) + ".gz.tmp", stamp(3)} {
+		assert.Empty(t, ioutil.WriteFile(fn+"."+name, []byte(name), 0644))
+	}
+
+	w, err := NewRotateWriter(fn, &RotateCfg{MaxBackups: 2, Compress: compress.CodecGzip})
+	assert.Empty(t, err)
+	defer w.Close()
+	assert.Eventually(t, func() bool {
+		names, _ := filepath.Glob(fn + ".2*")
+		sort.Strings(names)
+		return assert.ObjectsAreEqual([]string{fn + "." + stamp(2) + ".sz", fn + "." + stamp(3) + ".gz"}, names)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRotateWriterCompressError(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, &RotateCfg{Compress: compress.Codec(99)})
+	assert.Empty(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("a"))
+	assert.Empty(t, err)
+	assert.Empty(t, w.Rotate())
+	// 后台压缩的错误在之后的写入中返回, 历史文件保持未压缩
+	assert.Eventually(t, func() bool {
+		return w.Sync() != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Empty(t, w.Sync())
+	assert.Equal(t, []string{"a", ""}, readLogs(t, fn))
+}
+
+func TestRotateWriterLocked(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, nil)
+	assert.Empty(t, err)
+	_, err = NewRotateWriter(fn, nil)
+	assert.Equal(t, ErrLocked, err)
+	assert.Empty(t, w.Close())
+
+	w, err = NewRotateWriter(fn, nil)
+	assert.Empty(t, err)
+	assert.Empty(t, w.Close())
+}
+
+func TestRotateWriterZerolog(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewRotateWriter(fn, &RotateCfg{MaxSize: 64, SyncInterval: 10 * time.Millisecond})
+	assert.Empty(t, err)
+
+	logger := zerolog.New(w)
+	for i := 0; i < 10; i++ {
+		logger.Info().Int("seq", i).Msg("hello")
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, w.Sync())
+	assert.Empty(t, w.Close())
+
+	// 每条日志完整地落在某一个文件中
+	lines := strings.Split(strings.Join(readLogs(t, fn), ""), "\n")
+	assert.Equal(t, 11, len(lines))
+	for _, line := range lines[:10] {
+		assert.True(t, strings.HasPrefix(line, `{"level":"info"`), line)
+		assert.True(t, strings.HasSuffix(line, `"message":"hello"}`), line)
+	}
+}
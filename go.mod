@@ -8,8 +8,11 @@ require (
 	github.com/allegro/bigcache v1.2.1
 	github.com/gammazero/deque v0.0.0-20201010052221-3932da5530cc
 	github.com/gocql/gocql v0.0.0-20210310132943-486542b7b4b4
+	github.com/golang/snappy v0.0.2
 	github.com/juju/ratelimit v1.0.1
+	github.com/klauspost/compress v1.11.7
 	github.com/petermattis/goid v0.0.0-20180202154549-b0b1615b78e5
+	github.com/pierrec/lz4 v2.6.0+incompatible
 	github.com/rs/zerolog v1.20.0
 	github.com/samuel/go-zookeeper v0.0.0-20201211165307-7117e9ea2414
 	github.com/stretchr/testify v1.7.0